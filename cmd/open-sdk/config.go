@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/trancecho/open-sdk/config"
+	"github.com/trancecho/open-sdk/pkg/colorful"
+)
+
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing config subcommand")
+	}
+	switch args[0] {
+	case "keygen":
+		key, err := config.GenerateSecretKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+	case "encrypt", "decrypt":
+		// 明文只从标准输入读取，避免出现在进程参数和 shell 历史中
+		if args[0] == "encrypt" && len(args) != 1 || args[0] == "decrypt" && len(args) > 2 {
+			return fmt.Errorf("usage: open-sdk config encrypt < value, or open-sdk config decrypt [value]")
+		}
+		key, err := config.LoadSecretKey()
+		if err != nil {
+			return err
+		}
+		value := ""
+		if len(args) == 2 {
+			value = args[1]
+		} else if value, err = readValue(); err != nil {
+			return err
+		}
+		var out string
+		if args[0] == "encrypt" {
+			out, err = config.EncryptValue(key, value)
+		} else {
+			out, err = config.DecryptValue(key, value)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(out)
+	case "rekey":
+		return runConfigRekey(args[1:])
+	case "gen":
+		return runConfigGen(args[1:])
+	default:
+		return fmt.Errorf("unknown config subcommand %q", args[0])
+	}
+	return nil
+}
+
+// readValue 从标准输入读取一行，交互终端下先输出提示
+func readValue() (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "value: ")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty value")
+	}
+	return line, nil
+}
+
+// runConfigRekey 旧密钥取自环境变量，新密钥取自 -new-key-file
+func runConfigRekey(args []string) error {
+	fs := flag.NewFlagSet("config rekey", flag.ContinueOnError)
+	file := fs.String("file", "config.yaml", "配置文件路径")
+	newKeyFile := fs.String("new-key-file", "", "新密钥文件路径")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *newKeyFile == "" {
+		return fmt.Errorf("-new-key-file is required")
+	}
+	oldKey, err := config.LoadSecretKey()
+	if err != nil {
+		return err
+	}
+	newKey, err := config.ReadSecretKeyFile(*newKeyFile)
+	if err != nil {
+		return err
+	}
+	n, err := config.RekeyFile(*file, oldKey, newKey)
+	if err != nil {
+		return err
+	}
+	println(colorful.Green(fmt.Sprintf("%d values re-encrypted in %s", n, *file)))
+	return nil
+}
+
+// runConfigGen 生成配置模板，-check 校验已有文件，-schema 导出 JSON Schema
+func runConfigGen(args []string) error {
+	fs := flag.NewFlagSet("config gen", flag.ContinueOnError)
+	file := fs.String("file", "config.yaml", "配置文件路径")
+	force := fs.Bool("f", false, "覆盖已存在的配置文件")
+	check := fs.Bool("check", false, "校验已有配置文件而不是生成")
+	schema := fs.String("schema", "", "导出 JSON Schema 到指定文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case *check:
+		issues, err := config.CheckConfig(*file)
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			println(colorful.Yellow(issue.String()))
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("%d issues found in %s", len(issues), *file)
+		}
+		println(colorful.Green(*file + " matches the config schema"))
+	case *schema != "":
+		data, err := config.JSONSchema()
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(*schema, data, 0644); err != nil {
+			return err
+		}
+		println(colorful.Green("json schema written to " + *schema))
+	default:
+		return config.GenConfig(*file, *force)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/trancecho/open-sdk/pkg/colorful"
+)
+
+const usage = `usage: open-sdk <command> [arguments]
+
+commands:
+  config keygen                         生成新的配置加密密钥
+  config encrypt < value               加密从标准输入读取的配置值
+  config decrypt [value]                解密单个配置值，省略时从标准输入读取
+  config rekey [-file f] -new-key-file k 使用新密钥重新加密整个配置文件
+  config gen [-file f] [-f]             生成带注释和默认值的配置模板
+  config gen -check [-file f]           校验配置文件的未知键和缺失的必填键
+  config gen -schema <out.json>         导出配置的 JSON Schema
+  migrate [-config f] [-db key] [-dir d] up|down N|status|force V
+                                        执行数据库迁移
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "config":
+		err = runConfig(os.Args[2:])
+	case "migrate":
+		err = runMigrate(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		println(colorful.Red(err.Error()))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/trancecho/open-sdk/config"
+	"github.com/trancecho/open-sdk/database"
+	"github.com/trancecho/open-sdk/database/migrate"
+	"github.com/trancecho/open-sdk/pkg/colorful"
+)
+
+// runMigrate 对配置文件中的某个数据源执行 SQL 目录中的迁移
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	file := fs.String("config", "config.yaml", "配置文件路径")
+	key := fs.String("db", "*", "数据源 Key")
+	dir := fs.String("dir", "migrations", "迁移文件目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: open-sdk migrate [flags] up|down N|status|force V")
+	}
+	migrations, err := migrate.LoadFS(os.DirFS(*dir), ".")
+	if err != nil {
+		return err
+	}
+	config.LoadConfig(*file)
+	// 只连接要迁移的数据源，其余数据源不可达时不影响迁移
+	if err = database.InitDB(*key); err != nil {
+		return err
+	}
+	defer database.CloseAll()
+	db := database.GetDb(*key)
+	if db == nil {
+		return fmt.Errorf("datasource %s not found", *key)
+	}
+	m := migrate.New(db, migrate.WithMigrations(migrations...))
+	ctx := context.Background()
+
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		println(colorful.Green(fmt.Sprintf("%d migrations applied", n)))
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			if steps, err = strconv.Atoi(rest[0]); err != nil || steps <= 0 {
+				return fmt.Errorf("invalid down steps %q", rest[0])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		println(colorful.Green(fmt.Sprintf("%d migrations rolled back", n)))
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Dirty {
+				state = "dirty"
+			} else if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%6d  %-40s %s\n", s.Version, s.Name, state)
+		}
+	case "force":
+		if len(rest) == 0 {
+			return fmt.Errorf("usage: open-sdk migrate force V")
+		}
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", rest[0])
+		}
+		if err = m.Force(ctx, version); err != nil {
+			return err
+		}
+		println(colorful.Green(fmt.Sprintf("version forced to %d", version)))
+	default:
+		return fmt.Errorf("unknown migrate command %q", cmd)
+	}
+	return nil
+}
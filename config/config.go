@@ -6,45 +6,73 @@ import (
 	"github.com/spf13/viper"
 	"github.com/trancecho/open-sdk/pkg/colorful"
 	"github.com/trancecho/open-sdk/pkg/fs"
-	"gopkg.in/yaml.v3"
 	"os"
+	"sync/atomic"
 )
 
-var serveConfig *GlobalConfig
+var serveConfig atomic.Pointer[GlobalConfig]
+
+// decodeConfig 解码并解密到新的 GlobalConfig，成功后才替换当前配置
+func decodeConfig() (*GlobalConfig, error) {
+	conf := new(GlobalConfig)
+	if err := viper.Unmarshal(conf); err != nil {
+		return nil, errors.Wrap(err, "unmarshal")
+	}
+	if err := decryptSecrets(conf); err != nil {
+		return nil, errors.Wrap(err, "decrypt")
+	}
+	return conf, nil
+}
 
 func LoadConfig(configYml string) {
 	if !fs.FileExist(configYml) {
 		println("cannot find config file")
 		os.Exit(1)
 	}
-	serveConfig = new(GlobalConfig)
 	viper.SetConfigFile(configYml)
 	err := viper.ReadInConfig()
 	if err != nil {
 		println("Config Read failed: " + err.Error())
 		os.Exit(1)
 	}
-	err = viper.Unmarshal(serveConfig)
+	conf, err := decodeConfig()
+	if err != nil {
+		println("Config Load failed: " + err.Error())
+		os.Exit(1)
+	}
+	values, err := decodeSections()
 	if err != nil {
-		println("Config Unmarshal failed: " + err.Error())
+		println("Config Section Load failed: " + err.Error())
 		os.Exit(1)
 	}
+	serveConfig.Store(conf)
+	applySections(values)
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		println("Config fileHandle changed: ", e.Name)
 		_ = viper.ReadInConfig()
-		err = viper.Unmarshal(serveConfig)
+		conf, err := decodeConfig()
 		if err != nil {
-			println("New Config fileHandle Parse Failed: ", e.Name)
+			println("New Config fileHandle Parse Failed: ", e.Name, err.Error())
 			return
 		}
+		values, err := decodeSections()
+		if err != nil {
+			println("New Config fileHandle Section Load Failed: ", e.Name, err.Error())
+			return
+		}
+		serveConfig.Store(conf)
+		applySections(values)
 	})
 	viper.WatchConfig()
 }
 
 func GenConfig(configYml string, force bool) error {
 	if !fs.FileExist(configYml) || force {
-		data, _ := yaml.Marshal(&GlobalConfig{MODE: "dev"})
-		err := os.WriteFile(configYml, data, 0644)
+		data, err := GenerateTemplate()
+		if err != nil {
+			return errors.New(colorful.Red("Generate file with error: " + err.Error()))
+		}
+		err = os.WriteFile(configYml, data, 0644)
 		if err != nil {
 			return errors.New(colorful.Red("Generate file with error: " + err.Error()))
 		}
@@ -55,6 +83,7 @@ func GenConfig(configYml string, force bool) error {
 	return nil
 }
 
+// GetConfig 返回当前配置，热加载时整体替换，调用方不要长期持有旧指针
 func GetConfig() *GlobalConfig {
-	return serveConfig
+	return serveConfig.Load()
 }
@@ -0,0 +1,347 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置结构体字段可以使用以下 tag 描述自身，供模板生成、校验和 JSON Schema 导出使用
+//
+//	Port string `yaml:"Port" comment:"监听端口" required:"true"`
+const (
+	commentTag  = "comment"
+	requiredTag = "required"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldSchema 描述配置中的一个键
+type fieldSchema struct {
+	Key      string
+	Comment  string
+	Required bool
+	Type     reflect.Type
+	Default  reflect.Value
+}
+
+// Issue 配置校验发现的问题
+type Issue struct {
+	Path    string
+	Problem string
+}
+
+func (i Issue) String() string {
+	return i.Path + ": " + i.Problem
+}
+
+// rootFields 返回顶层配置键：GlobalConfig 的字段和所有已注册配置段
+func rootFields() []fieldSchema {
+	fields := fieldsOf(reflect.ValueOf(GlobalConfig{MODE: "dev"}))
+	for _, key := range sectionKeys() {
+		def := reflect.ValueOf(sectionDefaults(key))
+		fields = append(fields, fieldSchema{Key: key, Type: def.Type(), Default: def})
+	}
+	return fields
+}
+
+// fieldsOf 列出结构体值的配置字段，键名与 yaml 编码保持一致
+func fieldsOf(v reflect.Value) []fieldSchema {
+	t := v.Type()
+	fields := make([]fieldSchema, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields = append(fields, fieldSchema{
+			Key:      name,
+			Comment:  f.Tag.Get(commentTag),
+			Required: f.Tag.Get(requiredTag) == "true",
+			Type:     f.Type,
+			Default:  v.Field(i),
+		})
+	}
+	return fields
+}
+
+func isStructType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// GenerateTemplate 生成带默认值和字段注释的完整配置模板
+func GenerateTemplate() ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range rootFields() {
+		key, value, err := templateEntry(f)
+		if err != nil {
+			return nil, err
+		}
+		root.Content = append(root.Content, key, value)
+	}
+	return yaml.Marshal(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}})
+}
+
+func templateEntry(f fieldSchema) (*yaml.Node, *yaml.Node, error) {
+	key := &yaml.Node{Kind: yaml.ScalarNode, Value: f.Key}
+	value := &yaml.Node{}
+	if err := value.Encode(f.Default.Interface()); err != nil {
+		return nil, nil, err
+	}
+	if err := annotate(value, f.Type); err != nil {
+		return nil, nil, err
+	}
+	comment := f.Comment
+	if f.Required {
+		comment = strings.TrimSpace(comment + " (必填)")
+	}
+	// 空的结构体数组附带一个注释掉的示例元素
+	t := derefType(f.Type)
+	if t.Kind() == reflect.Slice && isStructType(derefType(t.Elem())) && len(value.Content) == 0 {
+		example, err := exampleElement(derefType(t.Elem()))
+		if err != nil {
+			return nil, nil, err
+		}
+		comment = strings.TrimSpace(comment + "\n示例:\n" + example)
+	}
+	if value.Kind == yaml.ScalarNode {
+		value.LineComment = comment
+	} else {
+		key.HeadComment = comment
+	}
+	return key, value, nil
+}
+
+// annotate 按类型为已编码的节点补充字段注释，并把 time.Duration 写成可读形式
+func annotate(node *yaml.Node, t reflect.Type) error {
+	t = derefType(t)
+	switch {
+	case t == durationType && node.Kind == yaml.ScalarNode:
+		var d int64
+		if err := node.Decode(&d); err == nil {
+			node.Tag, node.Value = "!!str", time.Duration(d).String()
+		}
+	case isStructType(t) && node.Kind == yaml.MappingNode:
+		fields := fieldsOf(reflect.New(t).Elem())
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			for _, f := range fields {
+				if f.Key != node.Content[i].Value {
+					continue
+				}
+				comment := f.Comment
+				if f.Required {
+					comment = strings.TrimSpace(comment + " (必填)")
+				}
+				if node.Content[i+1].Kind == yaml.ScalarNode {
+					node.Content[i+1].LineComment = comment
+				} else {
+					node.Content[i].HeadComment = comment
+				}
+				if err := annotate(node.Content[i+1], f.Type); err != nil {
+					return err
+				}
+			}
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && node.Kind == yaml.SequenceNode:
+		for _, elem := range node.Content {
+			if err := annotate(elem, t.Elem()); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := annotate(node.Content[i], t.Elem()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func exampleElement(t reflect.Type) (string, error) {
+	elem := &yaml.Node{}
+	if err := elem.Encode(reflect.New(t).Elem().Interface()); err != nil {
+		return "", err
+	}
+	if err := annotate(elem, t); err != nil {
+		return "", err
+	}
+	data, err := yaml.Marshal(&yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{elem}})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+// CheckConfig 对照配置结构校验已有配置文件，返回未知键和缺失的必填键
+// 键名比较不区分大小写，与 viper 的行为一致
+func CheckConfig(path string) ([]Issue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var issues []Issue
+	checkFields("", doc, rootFields(), &issues)
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues, nil
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func checkFields(path string, values map[string]any, fields []fieldSchema, issues *[]Issue) {
+	for k, v := range values {
+		matched := false
+		for _, f := range fields {
+			if strings.EqualFold(f.Key, k) {
+				matched = true
+				checkValue(joinPath(path, f.Key), v, f.Type, issues)
+				break
+			}
+		}
+		if !matched {
+			*issues = append(*issues, Issue{Path: joinPath(path, k), Problem: "unknown key"})
+		}
+	}
+	for _, f := range fields {
+		if !f.Required {
+			continue
+		}
+		var value any
+		for k, v := range values {
+			if strings.EqualFold(f.Key, k) {
+				value = v
+			}
+		}
+		if value == nil || value == "" {
+			*issues = append(*issues, Issue{Path: joinPath(path, f.Key), Problem: "missing required key"})
+		}
+	}
+}
+
+func checkValue(path string, value any, t reflect.Type, issues *[]Issue) {
+	if value == nil {
+		return
+	}
+	t = derefType(t)
+	switch {
+	case isStructType(t):
+		m, ok := value.(map[string]any)
+		if !ok {
+			*issues = append(*issues, Issue{Path: path, Problem: "expected mapping"})
+			return
+		}
+		checkFields(path, m, fieldsOf(reflect.New(t).Elem()), issues)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		list, ok := value.([]any)
+		if !ok {
+			*issues = append(*issues, Issue{Path: path, Problem: "expected sequence"})
+			return
+		}
+		for i, elem := range list {
+			checkValue(fmt.Sprintf("%s[%d]", path, i), elem, t.Elem(), issues)
+		}
+	case t.Kind() == reflect.Map:
+		m, ok := value.(map[string]any)
+		if !ok {
+			*issues = append(*issues, Issue{Path: path, Problem: "expected mapping"})
+			return
+		}
+		for k, v := range m {
+			checkValue(joinPath(path, k), v, t.Elem(), issues)
+		}
+	}
+}
+
+// JSONSchema 导出配置的 JSON Schema（draft-07），供编辑器自动补全使用
+func JSONSchema() ([]byte, error) {
+	schema := objectSchema(rootFields())
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "open-sdk config"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func objectSchema(fields []fieldSchema) map[string]any {
+	props := make(map[string]any, len(fields))
+	var required []string
+	for _, f := range fields {
+		prop := typeSchema(f.Type)
+		if f.Comment != "" {
+			prop["description"] = f.Comment
+		}
+		if f.Default.IsValid() && !f.Default.IsZero() && derefType(f.Type).Kind() != reflect.Struct {
+			if f.Type == durationType {
+				prop["default"] = time.Duration(f.Default.Int()).String()
+			} else {
+				prop["default"] = f.Default.Interface()
+			}
+		}
+		props[f.Key] = prop
+		if f.Required {
+			required = append(required, f.Key)
+		}
+	}
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	t = derefType(t)
+	switch {
+	case t == durationType:
+		return map[string]any{"type": []string{"string", "integer"}}
+	case isStructType(t):
+		return objectSchema(fieldsOf(reflect.New(t).Elem()))
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// EncPrefix 加密配置值的前缀，例如 `Password: enc:xxxx`
+	EncPrefix = "enc:"
+	// KeyEnv 存放 base64 编码密钥的环境变量
+	KeyEnv = "OPEN_SDK_CONFIG_KEY"
+	// KeyFileEnv 存放密钥文件路径的环境变量，文件内容为 base64 编码密钥
+	KeyFileEnv = "OPEN_SDK_CONFIG_KEY_FILE"
+
+	secretKeySize = 32 // AES-256
+)
+
+// encValuePattern 只匹配标准 base64 字符及末尾填充，不会越过引号等分隔符
+var encValuePattern = regexp.MustCompile(`enc:[A-Za-z0-9+/]+={0,2}`)
+
+// GenerateSecretKey 生成一个新的 base64 编码 AES-256 密钥
+func GenerateSecretKey() (string, error) {
+	key := make([]byte, secretKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// LoadSecretKey 依次从 OPEN_SDK_CONFIG_KEY 和 OPEN_SDK_CONFIG_KEY_FILE 读取密钥
+func LoadSecretKey() ([]byte, error) {
+	if v := os.Getenv(KeyEnv); v != "" {
+		return ParseSecretKey(v)
+	}
+	if p := os.Getenv(KeyFileEnv); p != "" {
+		return ReadSecretKeyFile(p)
+	}
+	return nil, errors.New("config secret key not set, use " + KeyEnv + " or " + KeyFileEnv)
+}
+
+// ReadSecretKeyFile 从文件读取 base64 编码密钥
+func ReadSecretKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read secret key file")
+	}
+	return ParseSecretKey(string(data))
+}
+
+// ParseSecretKey 解析 base64 编码的 32 字节密钥
+func ParseSecretKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.Wrap(err, "decode secret key")
+	}
+	if len(key) != secretKeySize {
+		return nil, fmt.Errorf("secret key must be %d bytes, got %d", secretKeySize, len(key))
+	}
+	return key, nil
+}
+
+// IsEncrypted 判断配置值是否为加密值
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncPrefix)
+}
+
+// EncryptValue 使用 AES-GCM 加密明文，返回带 enc: 前缀的配置值
+func EncryptValue(key []byte, plain string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return EncPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptValue 解密带 enc: 前缀的配置值，非加密值原样返回
+func DecryptValue(key []byte, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncPrefix))
+	if err != nil {
+		return "", errors.Wrap(err, "decode encrypted value")
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "decrypt value")
+	}
+	return string(plain), nil
+}
+
+// RekeyFile 将配置文件中所有 enc: 值用新密钥重新加密，保留文件其余内容和注释
+func RekeyFile(path string, oldKey, newKey []byte) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		count    int
+		rekeyErr error
+	)
+	out := encValuePattern.ReplaceAllStringFunc(string(data), func(value string) string {
+		if rekeyErr != nil {
+			return value
+		}
+		plain, err := DecryptValue(oldKey, value)
+		if err != nil {
+			rekeyErr = err
+			return value
+		}
+		enc, err := EncryptValue(newKey, plain)
+		if err != nil {
+			rekeyErr = err
+			return value
+		}
+		count++
+		return enc
+	})
+	if rekeyErr != nil {
+		return 0, rekeyErr
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return count, os.WriteFile(path, []byte(out), info.Mode().Perm())
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptSecrets 遍历配置结构体，解密所有 enc: 前缀的字符串字段
+// 只有存在加密值时才会读取密钥
+func decryptSecrets(target any) error {
+	d := &secretDecrypter{}
+	return d.walk(reflect.ValueOf(target))
+}
+
+type secretDecrypter struct {
+	key []byte
+}
+
+func (d *secretDecrypter) walk(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return d.walk(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if !v.CanSet() || v.Elem().Kind() == reflect.Ptr {
+			return d.walk(v.Elem())
+		}
+		// 接口中的值不可寻址，复制后处理再整体写回
+		elem, err := d.walkCopy(v.Elem())
+		if err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := d.walk(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := d.walk(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map 的值不可寻址，逐个复制后处理再写回，使嵌套的 map、切片和结构体中的值也能解密
+		for _, k := range v.MapKeys() {
+			elem, err := d.walkCopy(v.MapIndex(k))
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(k, elem)
+		}
+	case reflect.String:
+		if !v.CanSet() || !IsEncrypted(v.String()) {
+			return nil
+		}
+		plain, err := d.decrypt(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(plain)
+	}
+	return nil
+}
+
+// walkCopy 把 v 复制到可寻址的新值中处理并返回
+func (d *secretDecrypter) walkCopy(v reflect.Value) (reflect.Value, error) {
+	cp := reflect.New(v.Type()).Elem()
+	cp.Set(v)
+	if err := d.walk(cp); err != nil {
+		return reflect.Value{}, err
+	}
+	return cp, nil
+}
+
+func (d *secretDecrypter) decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if d.key == nil {
+		key, err := LoadSecretKey()
+		if err != nil {
+			return "", err
+		}
+		d.key = key
+	}
+	return DecryptValue(d.key, value)
+}
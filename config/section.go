@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	sections   = make(map[string]*sectionEntry)
+	sectionMux sync.RWMutex
+)
+
+type sectionEntry struct {
+	target   any           // 使用方持有的结构体指针
+	defaults reflect.Value // 注册时的值快照，重新加载前先还原
+	current  atomic.Value  // 最近一次加载的新值（与 target 同类型的指针），加载后不再修改
+}
+
+// Register 注册第三方模块的配置段，section 必须是结构体指针，其当前值即默认值
+// 配置加载和热更新时会用 key 对应的配置段填充 section，GenConfig 也会输出该段。
+// 热更新会原地覆盖 section，与其他 goroutine 的读取存在数据竞争；
+// 需要在运行中并发读取时使用 SectionOf 获取每次加载后原子替换的只读快照
+//
+//	config.Register("captcha", &captcha.Config{Width: 240})
+func Register(key string, section any) {
+	if key == "" {
+		panic("config: section key is empty")
+	}
+	rv := reflect.ValueOf(section)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("config: section %q must be a non-nil pointer to struct", key))
+	}
+	key = strings.ToLower(key)
+	if globalKeys()[key] {
+		panic("config: section key " + key + " collides with a GlobalConfig field")
+	}
+	sectionMux.Lock()
+	defer sectionMux.Unlock()
+	if _, ok := sections[key]; ok {
+		panic("config: duplicate section key " + key)
+	}
+	defaults := reflect.New(rv.Elem().Type()).Elem()
+	defaults.Set(rv.Elem())
+	entry := &sectionEntry{target: section, defaults: defaults}
+	current := reflect.New(defaults.Type())
+	current.Elem().Set(defaults)
+	entry.current.Store(current.Interface())
+	sections[key] = entry
+}
+
+// globalKeys 返回 GlobalConfig 顶层字段在配置文件中可能使用的 key（小写），含字段名和 yaml 标签
+func globalKeys() map[string]bool {
+	t := reflect.TypeOf(GlobalConfig{})
+	keys := make(map[string]bool, t.NumField()*2)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		keys[strings.ToLower(f.Name)] = true
+		if name, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); name != "" {
+			keys[strings.ToLower(name)] = true
+		}
+	}
+	return keys
+}
+
+// Section 获取已注册的配置段，未注册返回 nil
+func Section(key string) any {
+	sectionMux.RLock()
+	defer sectionMux.RUnlock()
+	if entry, ok := sections[strings.ToLower(key)]; ok {
+		return entry.target
+	}
+	return nil
+}
+
+// SectionOf 返回配置段最近一次加载的快照，加载前为注册时的默认值；
+// 未注册或类型不是 *T 时返回 nil。快照在热更新时整体替换，可并发读取但不能修改
+//
+//	conf := config.SectionOf[captcha.Config]("captcha")
+func SectionOf[T any](key string) *T {
+	sectionMux.RLock()
+	entry, ok := sections[strings.ToLower(key)]
+	sectionMux.RUnlock()
+	if !ok {
+		return nil
+	}
+	v, _ := entry.current.Load().(*T)
+	return v
+}
+
+// sectionDefaults 返回配置段注册时的默认值
+func sectionDefaults(key string) any {
+	sectionMux.RLock()
+	defer sectionMux.RUnlock()
+	return sections[key].defaults.Interface()
+}
+
+// sectionKeys 返回排序后的已注册配置段 key
+func sectionKeys() []string {
+	sectionMux.RLock()
+	defer sectionMux.RUnlock()
+	keys := make([]string, 0, len(sections))
+	for k := range sections {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeSections 从 viper 中解码所有已注册的配置段到新值，文件中缺失的字段保持默认值。
+// 任一配置段失败时不修改使用方持有的结构体
+func decodeSections() (map[string]reflect.Value, error) {
+	values := make(map[string]reflect.Value)
+	for _, key := range sectionKeys() {
+		value := reflect.New(reflect.TypeOf(Section(key)).Elem())
+		value.Elem().Set(reflect.ValueOf(sectionDefaults(key)))
+		if err := viper.UnmarshalKey(key, value.Interface()); err != nil {
+			return nil, fmt.Errorf("unmarshal section %s: %w", key, err)
+		}
+		if err := decryptSecrets(value.Interface()); err != nil {
+			return nil, fmt.Errorf("decrypt section %s: %w", key, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// applySections 发布解码好的配置段快照，并写入使用方持有的结构体
+func applySections(values map[string]reflect.Value) {
+	sectionMux.Lock()
+	defer sectionMux.Unlock()
+	for key, value := range values {
+		entry := sections[key]
+		entry.current.Store(value.Interface())
+		reflect.ValueOf(entry.target).Elem().Set(value.Elem())
+	}
+}
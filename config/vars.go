@@ -1,62 +1,131 @@
 package config
 
+import "time"
+
 type GlobalConfig struct {
-	AppName   string       `yaml:"AppName"`
-	MODE      string       `yaml:"Mode"` // dev或prod
-	VERSION   string       `yaml:"Version"`
-	Host      string       `yaml:"Host"`
-	Port      string       `yaml:"Port"`
-	Databases []Datasource `yaml:"Databases"`
-	Caches    []Cache      `yaml:"Caches"`
+	AppName   string       `yaml:"AppName" comment:"应用名称，同时作为 JWT 签发者" required:"true"`
+	MODE      string       `yaml:"Mode" comment:"运行模式，dev 或 prod" required:"true"`
+	VERSION   string       `yaml:"Version" comment:"应用版本"`
+	Host      string       `yaml:"Host" comment:"监听地址"`
+	Port      string       `yaml:"Port" comment:"监听端口" required:"true"`
+	Databases []Datasource `yaml:"Databases" comment:"数据源列表，通过 Key 在 database.GetDb 中获取"`
+	Caches    []Cache      `yaml:"Caches" comment:"缓存列表，通过 Key 在 cache.GetCache 中获取"`
 	Minio     struct {
 		Endpoint        string `yaml:"endpoint"`
 		AccessKeyID     string `yaml:"accessKeyID"`
 		SecretAccessKey string `yaml:"secretAccessKey"`
 		UseSSL          bool   `yaml:"useSSL"`
-	} `yaml:"minio"`
-	Elasticsearch struct {
-		Addresses []string `yaml:"addresses"`
-	} `yaml:"elasticsearch"`
-	Wechat struct {
+	} `yaml:"minio" comment:"MinIO 对象存储"`
+	Elasticsearch Elasticsearch `yaml:"elasticsearch" comment:"Elasticsearch 集群，由 esx.InitElastic 使用"`
+	Wechat        struct {
 		AppId     string `yaml:"appid"`
 		AppSecret string `yaml:"appsecret"`
-	} `yaml:"wechat"`
+	} `yaml:"wechat" comment:"微信开放平台"`
 	MQ struct {
-		Broker        string `yaml:"broker"`         // MQ 的类型（例如 "rabbitmq", "kafka"）
-		Address       string `yaml:"address"`        // MQ 地址（例如 RabbitMQ 的主机地址）
-		Port          string `yaml:"port"`           // MQ 端口
-		Username      string `yaml:"username"`       // MQ 用户名
-		Password      string `yaml:"password"`       // MQ 密码
-		VirtualHost   string `yaml:"virtual_host"`   // RabbitMQ 的虚拟主机
-		Exchange      string `yaml:"exchange"`       // 默认交换机
-		ExchangeType  string `yaml:"exchange_type"`  // 交换机类型（如 "direct", "fanout", "topic"）
-		QueueName     string `yaml:"queue_name"`     // 默认队列名称
-		RetryAttempts int    `yaml:"retry_attempts"` // 重试次数
-		RetryDelay    int    `yaml:"retry_delay"`    // 重试延迟（秒）
-	} `yaml:"mq"`
+		Broker        string `yaml:"broker" comment:"MQ 的类型（例如 rabbitmq、kafka）"`
+		Address       string `yaml:"address" comment:"MQ 地址（例如 RabbitMQ 的主机地址）"`
+		Port          string `yaml:"port" comment:"MQ 端口"`
+		Username      string `yaml:"username" comment:"MQ 用户名"`
+		Password      string `yaml:"password" comment:"MQ 密码"`
+		VirtualHost   string `yaml:"virtual_host" comment:"RabbitMQ 的虚拟主机"`
+		Exchange      string `yaml:"exchange" comment:"默认交换机"`
+		ExchangeType  string `yaml:"exchange_type" comment:"交换机类型（如 direct、fanout、topic）"`
+		QueueName     string `yaml:"queue_name" comment:"默认队列名称"`
+		RetryAttempts int    `yaml:"retry_attempts" comment:"重试次数"`
+		RetryDelay    int    `yaml:"retry_delay" comment:"重试延迟（秒）"`
+	} `yaml:"mq" comment:"消息队列"`
 	Jwt struct {
 		//关键点：不要留secret，甚至是_secret也不行
 		Mundo    string `yaml:"mundo"`
 		Offercat string `yaml:"offercat"`
-	} `yaml:"jwt"`
+	} `yaml:"jwt" comment:"JWT 签名密钥，可使用 enc: 加密"`
 	Apmq struct {
 		Url string `yaml:"url"`
 	} `yaml:"apmq"`
+	FieldEncryption FieldEncryption `yaml:"FieldEncryption" comment:"模型字段加密（gorm serializer:encrypted），密钥可使用 enc: 加密"`
+	Pagination      Pagination      `yaml:"Pagination" comment:"分页"`
+}
+
+// Pagination 分页配置
+type Pagination struct {
+	CursorSecret string `yaml:"CursorSecret" comment:"游标签名密钥，多实例部署时需相同，可使用 enc: 加密；为空时使用进程内随机密钥，重启后旧游标失效"`
+}
+
+// FieldEncryption 字段加密密钥，轮换时新增版本并把 ActiveVersion 指向它，旧版本保留用于解密
+type FieldEncryption struct {
+	ActiveVersion int        `yaml:"ActiveVersion" comment:"加密新数据使用的密钥版本，0 为最大版本"`
+	Keys          []FieldKey `yaml:"Keys" comment:"数据密钥列表"`
+	BlindIndexKey string     `yaml:"BlindIndexKey" comment:"盲索引 HMAC 密钥（base64 编码的 32 字节），更换后需重建所有盲索引"`
+}
+
+type FieldKey struct {
+	Version int    `yaml:"Version" comment:"密钥版本，正整数" required:"true"`
+	Key     string `yaml:"Key" comment:"base64 编码的 32 字节 AES 密钥，可用 open-sdk config keygen 生成" required:"true"`
+}
+
+// Elasticsearch 集群配置，Enable 为 false 时 esx 客户端不连接集群，所有操作为空操作
+type Elasticsearch struct {
+	Enable             bool          `yaml:"enable" comment:"是否启用"`
+	Addresses          []string      `yaml:"addresses" comment:"节点地址，为空时使用 ELASTICSEARCH_URL 或 http://localhost:9200"`
+	Username           string        `yaml:"username" comment:"Basic 认证用户名"`
+	Password           string        `yaml:"password" comment:"Basic 认证密码，可使用 enc: 加密"`
+	APIKey             string        `yaml:"apiKey" comment:"base64 编码的 API Key，设置后优先于用户名密码"`
+	CACert             string        `yaml:"caCert" comment:"CA 证书 PEM 文件路径，用于自签名证书的集群"`
+	InsecureSkipVerify bool          `yaml:"insecureSkipVerify" comment:"跳过证书校验，仅用于开发环境"`
+	MaxRetries         int           `yaml:"maxRetries" comment:"502、503、504 和网络错误的最大重试次数，0 为默认 3 次，负数不重试"`
+	RetryBackoff       time.Duration `yaml:"retryBackoff" comment:"首次重试等待时间，之后每次翻倍，默认 100ms"`
+	DialTimeout        time.Duration `yaml:"dialTimeout" comment:"建立连接超时，默认 5s"`
+	RequestTimeout     time.Duration `yaml:"requestTimeout" comment:"等待响应头超时，默认 30s"`
+	MaxIdleConns       int           `yaml:"maxIdleConns" comment:"每个节点的最大空闲连接数，默认 10"`
+	Refresh            string        `yaml:"refresh" comment:"写入后的刷新策略：true、wait_for、false，默认使用集群设置（不等待刷新）"`
 }
 
 type Datasource struct {
-	Key      string `yaml:"Key"`
-	Type     string `yaml:"Type"`
+	Key      string `yaml:"Key" comment:"数据源标识，为空时记为 *"`
+	Type     string `yaml:"Type" comment:"驱动类型：mysql、postgres、sqlite、sqlserver" required:"true"`
 	IP       string `yaml:"Ip"`
 	PORT     string `yaml:"Port"`
 	USER     string `yaml:"User"`
 	PASSWORD string `yaml:"Password"`
+	DATABASE string `yaml:"Database" comment:"库名，sqlite 为数据库文件路径"`
+
+	Params         string        `yaml:"Params" comment:"附加 DSN 参数，URL 查询串格式，覆盖驱动默认值，如 loc=UTC&charset=utf8"`
+	TLS            string        `yaml:"TLS" comment:"TLS 模式：mysql 为 true/skip-verify/preferred，postgres 为 sslmode，sqlserver 为 encrypt"`
+	ConnectTimeout time.Duration `yaml:"ConnectTimeout" comment:"建立连接超时，0 为驱动默认"`
+	ReadTimeout    time.Duration `yaml:"ReadTimeout" comment:"读超时，仅 mysql 生效"`
+	WriteTimeout   time.Duration `yaml:"WriteTimeout" comment:"写超时，仅 mysql 生效"`
+
+	MaxOpenConns    int           `yaml:"MaxOpenConns" comment:"最大打开连接数，0 为不限制"`
+	MaxIdleConns    int           `yaml:"MaxIdleConns" comment:"最大空闲连接数，0 为 database/sql 默认值 2"`
+	ConnMaxLifetime time.Duration `yaml:"ConnMaxLifetime" comment:"连接最大存活时间，0 为不限制"`
+	ConnMaxIdleTime time.Duration `yaml:"ConnMaxIdleTime" comment:"连接最大空闲时间，0 为不限制"`
+
+	ConnectRetries int           `yaml:"ConnectRetries" comment:"启动时连接失败的重试次数，0 为默认 3 次，负数不重试"`
+	RetryBackoff   time.Duration `yaml:"RetryBackoff" comment:"首次重试等待时间，之后每次翻倍，最长 30s，默认 1s"`
+
+	LogLevel      string        `yaml:"LogLevel" comment:"SQL 日志级别（写入 logx）：silent、error、warn、info，默认 silent"`
+	SlowThreshold time.Duration `yaml:"SlowThreshold" comment:"慢 SQL 阈值，默认 1s"`
+	RedactParams  bool          `yaml:"RedactParams" comment:"SQL 日志中不展开绑定参数"`
+	Trace         bool          `yaml:"Trace" comment:"为每条 SQL 创建 OpenTelemetry span"`
+	Audit         bool          `yaml:"Audit" comment:"自动填充 created_by/updated_by 并记录变更历史到 audit_logs 表"`
+	Tenant        bool          `yaml:"Tenant" comment:"按 context 中的租户隔离实现 tenant.TenantScoped 的模型"`
+
+	Replicas []Replica `yaml:"Replicas" comment:"只读副本，配置后普通查询走副本，写入和事务走主库"`
+	Policy   string    `yaml:"Policy" comment:"副本负载均衡策略：random、round-robin、least-latency，默认 random"`
+}
+
+// Replica 只读副本，未填写的连接字段沿用所属数据源的配置
+type Replica struct {
+	IP       string `yaml:"Ip" required:"true"`
+	PORT     string `yaml:"Port"`
+	USER     string `yaml:"User"`
+	PASSWORD string `yaml:"Password"`
 	DATABASE string `yaml:"Database"`
 }
 
 type Cache struct {
-	Key      string `yaml:"Key"`
-	Type     string `yaml:"Type"`
+	Key      string `yaml:"Key" comment:"缓存标识，为空时记为 *"`
+	Type     string `yaml:"Type" comment:"驱动类型，如 redis" required:"true"`
 	IP       string `yaml:"Ip"`
 	PORT     string `yaml:"Port"`
 	PASSWORD string `yaml:"Password"`
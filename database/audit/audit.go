@@ -0,0 +1,434 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/trancecho/open-sdk/logx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+const (
+	table       = "audit_logs"
+	callbackKey = "audit"
+	beforeKey   = "audit:before"
+
+	createdByField = "CreatedBy"
+	updatedByField = "UpdatedBy"
+)
+
+// Plugin gorm 审计插件：
+//   - 新增时填充 CreatedBy、UpdatedBy，更新时填充 UpdatedBy，操作人取自 Statement 的 context
+//   - 在同一事务中把新增、更新、删除的变更列写入 audit_logs 表
+//
+// 更新和删除前会按条件查询旧值，带来额外开销；不需要历史的表用 WithExcludeTables 排除
+type Plugin struct {
+	principal func(ctx context.Context) string
+	exclude   map[string]bool
+	ignore    map[string]bool
+	masked    map[string]bool
+	maxRows   int
+}
+
+type Option func(*Plugin)
+
+// New 创建审计插件，通过 db.Use 启用，也可在数据源配置中设置 Audit: true
+func New(opts ...Option) *Plugin {
+	p := &Plugin{
+		principal: logx.UserID,
+		exclude:   map[string]bool{table: true},
+		ignore:    map[string]bool{"created_at": true, "updated_at": true, "created_by": true, "updated_by": true},
+		masked:    map[string]bool{},
+		maxRows:   1000,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithPrincipal 自定义从 context 取操作人的方式，默认 logx.UserID
+func WithPrincipal(fn func(ctx context.Context) string) Option {
+	return func(p *Plugin) {
+		p.principal = fn
+	}
+}
+
+// WithExcludeTables 不记录变更历史的表，CreatedBy/UpdatedBy 仍会填充
+func WithExcludeTables(tables ...string) Option {
+	return func(p *Plugin) {
+		for _, t := range tables {
+			p.exclude[t] = true
+		}
+	}
+}
+
+// WithIgnoreColumns 不计入变更的列，默认忽略时间戳和操作人列
+func WithIgnoreColumns(columns ...string) Option {
+	return func(p *Plugin) {
+		for _, c := range columns {
+			p.ignore[c] = true
+		}
+	}
+}
+
+// WithMaskedColumns 只记录发生变更、不记录值的列，如密码
+func WithMaskedColumns(columns ...string) Option {
+	return func(p *Plugin) {
+		for _, c := range columns {
+			p.masked[c] = true
+		}
+	}
+}
+
+// WithMaxRows 单条语句最多记录的行数，超过时跳过该语句的历史记录，默认 1000
+func WithMaxRows(n int) Option {
+	return func(p *Plugin) {
+		p.maxRows = n
+	}
+}
+
+func (p *Plugin) Name() string {
+	return "open-sdk:audit"
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, reg := range []struct {
+		name string
+		fn   func(string, func(*gorm.DB)) error
+		cb   func(*gorm.DB)
+	}{
+		{"before_create", cb.Create().Before("gorm:create").Register, p.beforeCreate},
+		{"after_create", cb.Create().After("gorm:create").Register, p.afterCreate},
+		{"before_update", cb.Update().Before("gorm:update").Register, p.beforeUpdate},
+		{"after_update", cb.Update().After("gorm:update").Register, p.afterUpdate},
+		{"before_delete", cb.Delete().Before("gorm:delete").Register, p.loadBefore},
+		{"after_delete", cb.Delete().After("gorm:delete").Register, p.afterDelete},
+	} {
+		if err := reg.fn(callbackKey+":"+reg.name, reg.cb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Plugin) actor(db *gorm.DB) string {
+	if db.Statement.Context == nil {
+		return ""
+	}
+	return p.principal(db.Statement.Context)
+}
+
+func (p *Plugin) tracked(db *gorm.DB) bool {
+	return db.Error == nil && db.Statement.Schema != nil && !p.exclude[db.Statement.Table]
+}
+
+func (p *Plugin) beforeCreate(db *gorm.DB) {
+	s := db.Statement.Schema
+	if db.Error != nil || s == nil {
+		return
+	}
+	actor := p.actor(db)
+	if actor == "" {
+		return
+	}
+	for _, name := range []string{createdByField, updatedByField} {
+		field := s.LookUpField(name)
+		if field == nil {
+			continue
+		}
+		eachRecord(db.Statement.ReflectValue, func(rv reflect.Value) {
+			if _, zero := field.ValueOf(db.Statement.Context, rv); zero {
+				_ = field.Set(db.Statement.Context, rv, actor)
+			}
+		})
+	}
+}
+
+func (p *Plugin) afterCreate(db *gorm.DB) {
+	if !p.tracked(db) {
+		return
+	}
+	s := db.Statement.Schema
+	var logs []Log
+	eachRecord(db.Statement.ReflectValue, func(rv reflect.Value) {
+		changes := make(map[string]Change)
+		for _, field := range s.Fields {
+			if field.DBName == "" || p.ignore[field.DBName] {
+				continue
+			}
+			if v, zero := field.ValueOf(db.Statement.Context, rv); !zero {
+				changes[field.DBName] = Change{After: p.value(field.DBName, v)}
+			}
+		}
+		logs = append(logs, p.newLog(db, ActionCreate, primaryKey(db, rv), changes))
+	})
+	p.write(db, logs)
+}
+
+func (p *Plugin) beforeUpdate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	if db.Statement.Schema.LookUpField(updatedByField) != nil {
+		if actor := p.actor(db); actor != "" {
+			db.Statement.SetColumn(updatedByField, actor, true)
+			// 指定了 Select 时需把操作人列加入更新范围
+			if len(db.Statement.Selects) > 0 && db.Statement.Selects[0] != "*" {
+				db.Statement.Selects = append(db.Statement.Selects, updatedByField)
+			}
+		}
+	}
+	p.loadBefore(db)
+}
+
+// loadBefore 查询将被更新或删除的行的当前值，按主键保存到 Statement 上
+func (p *Plugin) loadBefore(db *gorm.DB) {
+	if !p.tracked(db) || len(db.Statement.Schema.PrimaryFields) == 0 {
+		return
+	}
+	stmt := db.Statement
+	var exprs []clause.Expression
+	if where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where); ok {
+		exprs = append(exprs, where.Exprs...)
+	}
+	// gorm 在执行阶段才把模型的主键加入条件，这里提前加入
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		for _, field := range stmt.Schema.PrimaryFields {
+			if v, zero := field.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+				exprs = append(exprs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: v})
+			}
+		}
+	}
+	if len(exprs) == 0 {
+		// 与 gorm 一致，没有条件的更新和删除会被拒绝（除非 AllowGlobalUpdate）
+		if !db.AllowGlobalUpdate {
+			return
+		}
+	}
+	var rows []map[string]any
+	query := p.session(db).Table(stmt.Table).Clauses(clause.Where{Exprs: exprs}).Limit(p.maxRows + 1)
+	if !stmt.Unscoped && stmt.Schema.LookUpField("DeletedAt") != nil {
+		query = query.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "deleted_at"}, Value: nil})
+	}
+	if err := query.Find(&rows).Error; err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	if len(rows) > p.maxRows {
+		log.Println("audit: skip history of", stmt.Table, "affecting more than", p.maxRows, "rows")
+		return
+	}
+	db.InstanceSet(beforeKey, rows)
+}
+
+func (p *Plugin) afterUpdate(db *gorm.DB) {
+	before, ok := p.before(db)
+	if !ok || len(before) == 0 {
+		return
+	}
+	s := db.Statement.Schema
+	pks := make([]string, len(s.PrimaryFields))
+	for i, field := range s.PrimaryFields {
+		pks[i] = field.DBName
+	}
+	// 按旧值中的主键重新查询，更新后的行可能已不满足原条件
+	ids := make([][]any, len(before))
+	for i, row := range before {
+		for _, pk := range pks {
+			ids[i] = append(ids[i], row[pk])
+		}
+	}
+	var after []map[string]any
+	query := p.session(db).Table(db.Statement.Table)
+	if len(pks) == 1 {
+		values := make([]any, len(ids))
+		for i, id := range ids {
+			values[i] = id[0]
+		}
+		query = query.Where(clause.IN{Column: clause.Column{Name: pks[0]}, Values: values})
+	} else {
+		columns := make([]clause.Column, len(pks))
+		for i, pk := range pks {
+			columns[i] = clause.Column{Name: pk}
+		}
+		values := make([]any, len(ids))
+		for i, id := range ids {
+			values[i] = id
+		}
+		query = query.Where(clause.IN{Column: columns, Values: values})
+	}
+	if err := query.Find(&after).Error; err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	afterByID := make(map[string]map[string]any, len(after))
+	for _, row := range after {
+		afterByID[rowID(row, pks)] = row
+	}
+
+	var logs []Log
+	for _, old := range before {
+		id := rowID(old, pks)
+		current, ok := afterByID[id]
+		if !ok {
+			continue
+		}
+		changes := make(map[string]Change)
+		for column, v := range current {
+			if p.ignore[column] {
+				continue
+			}
+			if !equal(old[column], v) {
+				changes[column] = Change{Before: p.value(column, old[column]), After: p.value(column, v)}
+			}
+		}
+		if len(changes) > 0 {
+			logs = append(logs, p.newLog(db, ActionUpdate, id, changes))
+		}
+	}
+	p.write(db, logs)
+}
+
+func (p *Plugin) afterDelete(db *gorm.DB) {
+	before, ok := p.before(db)
+	if !ok {
+		return
+	}
+	pks := make([]string, len(db.Statement.Schema.PrimaryFields))
+	for i, field := range db.Statement.Schema.PrimaryFields {
+		pks[i] = field.DBName
+	}
+	logs := make([]Log, 0, len(before))
+	for _, old := range before {
+		changes := make(map[string]Change, len(old))
+		for column, v := range old {
+			if v != nil && !p.ignore[column] {
+				changes[column] = Change{Before: p.value(column, v)}
+			}
+		}
+		logs = append(logs, p.newLog(db, ActionDelete, rowID(old, pks), changes))
+	}
+	p.write(db, logs)
+}
+
+func (p *Plugin) before(db *gorm.DB) ([]map[string]any, bool) {
+	if !p.tracked(db) {
+		return nil, false
+	}
+	v, ok := db.InstanceGet(beforeKey)
+	if !ok {
+		return nil, false
+	}
+	return v.([]map[string]any), true
+}
+
+// session 与当前语句共用连接和事务的新会话，始终走主库
+func (p *Plugin) session(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Clauses(dbresolver.Write)
+}
+
+func (p *Plugin) newLog(db *gorm.DB, action, id string, changes map[string]Change) Log {
+	data, _ := json.Marshal(changes)
+	return Log{
+		Table:     db.Statement.Table,
+		RecordID:  id,
+		Action:    action,
+		Actor:     p.actor(db),
+		RequestID: logx.RequestID(db.Statement.Context),
+		Changes:   string(data),
+		CreatedAt: time.Now(),
+	}
+}
+
+func (p *Plugin) write(db *gorm.DB, logs []Log) {
+	if len(logs) == 0 || db.Error != nil {
+		return
+	}
+	if err := p.session(db).Create(&logs).Error; err != nil {
+		_ = db.AddError(err)
+	}
+}
+
+func (p *Plugin) value(column string, v any) any {
+	if p.masked[column] {
+		return "******"
+	}
+	return normalize(v)
+}
+
+// eachRecord 对单条记录或切片中的每条记录执行 fn
+func eachRecord(rv reflect.Value, fn func(rv reflect.Value)) {
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflect.Indirect(rv.Index(i))
+			if elem.Kind() == reflect.Struct {
+				fn(elem)
+			}
+		}
+	case reflect.Struct:
+		fn(rv)
+	}
+}
+
+func primaryKey(db *gorm.DB, rv reflect.Value) string {
+	fields := db.Statement.Schema.PrimaryFields
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		values[i], _ = field.ValueOf(db.Statement.Context, rv)
+	}
+	return recordID(values)
+}
+
+func rowID(row map[string]any, pks []string) string {
+	values := make([]any, len(pks))
+	for i, pk := range pks {
+		values[i] = row[pk]
+	}
+	return recordID(values)
+}
+
+// normalize 统一不同驱动扫描出的值类型，便于比较和序列化
+func normalize(v any) any {
+	switch x := v.(type) {
+	case []byte:
+		return string(x)
+	case *time.Time:
+		if x == nil {
+			return nil
+		}
+		return *x
+	case gorm.DeletedAt:
+		if !x.Valid {
+			return nil
+		}
+		return x.Time
+	}
+	if valuer, ok := v.(interface{ Value() (any, error) }); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		if value, err := valuer.Value(); err == nil {
+			return normalize(value)
+		}
+	}
+	return v
+}
+
+func equal(a, b any) bool {
+	a, b = normalize(a), normalize(b)
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// 变更动作
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Log 一条记录的一次变更
+type Log struct {
+	ID        int64  `gorm:"primaryKey" json:"id"`
+	Table     string `gorm:"column:table_name;size:64;index:idx_audit_record,priority:1" json:"table"`
+	RecordID  string `gorm:"size:128;index:idx_audit_record,priority:2" json:"record_id"`
+	Action    string `gorm:"size:16" json:"action"`
+	Actor     string `gorm:"size:64;index" json:"actor"`
+	RequestID string `gorm:"size:64" json:"request_id"`
+	// Changes 变更的列，JSON 格式：{"列名": {"before": 旧值, "after": 新值}}
+	Changes   string    `gorm:"type:text" json:"-"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (Log) TableName() string {
+	return table
+}
+
+// Change 单列的变更前后值，新增时 Before 为空，删除时 After 为空
+type Change struct {
+	Before any `json:"before,omitempty"`
+	After  any `json:"after,omitempty"`
+}
+
+// Diff 解析 Changes
+func (l Log) Diff() (map[string]Change, error) {
+	changes := make(map[string]Change)
+	if l.Changes == "" {
+		return changes, nil
+	}
+	err := json.Unmarshal([]byte(l.Changes), &changes)
+	return changes, err
+}
+
+func (l Log) MarshalJSON() ([]byte, error) {
+	type plain Log
+	changes, err := l.Diff()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		plain
+		Changes map[string]Change `json:"changes"`
+	}{plain(l), changes})
+}
+
+// Migrate 创建审计日志表
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Log{})
+}
+
+// History 按时间顺序返回 model 对应表中主键为 id 的记录的变更历史，复合主键按主键顺序传入
+//
+//	logs, err := audit.History(ctx, database.GetDb("MainMysql"), &User{}, 1)
+func History(ctx context.Context, db *gorm.DB, model any, id ...any) ([]Log, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	if len(id) == 0 {
+		return nil, fmt.Errorf("audit history of %s requires record id", stmt.Table)
+	}
+	var logs []Log
+	err := db.WithContext(ctx).Clauses(dbresolver.Write).
+		Where("table_name = ? AND record_id = ?", stmt.Table, recordID(id)).
+		Order("id").Find(&logs).Error
+	return logs, err
+}
+
+func recordID(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(normalize(v))
+	}
+	return strings.Join(parts, ",")
+}
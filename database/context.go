@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+type txContextKey struct{}
+
+// txState 一层事务（或保存点）及其提交后回调
+type txState struct {
+	key    string
+	tx     *gorm.DB
+	parent *txState
+	hooks  []func(ctx context.Context)
+}
+
+type txContext struct {
+	byKey   map[string]*txState
+	current *txState
+}
+
+// NewTxContext 把数据源 key 对应的事务放入 context，使用该 context 的仓储调用会加入此事务
+func NewTxContext(ctx context.Context, key string, tx *gorm.DB) context.Context {
+	ctx, _ = withTxState(ctx, key, tx)
+	return ctx
+}
+
+func withTxState(ctx context.Context, key string, tx *gorm.DB) (context.Context, *txState) {
+	if key == "" {
+		key = "*"
+	}
+	prev, _ := ctx.Value(txContextKey{}).(*txContext)
+	next := &txContext{byKey: make(map[string]*txState)}
+	if prev != nil {
+		for k, v := range prev.byKey {
+			next.byKey[k] = v
+		}
+	}
+	state := &txState{key: key, tx: tx, parent: next.byKey[key]}
+	next.byKey[key] = state
+	next.current = state
+	return context.WithValue(ctx, txContextKey{}, next), state
+}
+
+// TxFromContext 取出 context 中数据源 key 对应的事务
+func TxFromContext(ctx context.Context, key string) (*gorm.DB, bool) {
+	if state := txStateOf(ctx, key); state != nil {
+		return state.tx, true
+	}
+	return nil, false
+}
+
+func txStateOf(ctx context.Context, key string) *txState {
+	if key == "" {
+		key = "*"
+	}
+	if txc, ok := ctx.Value(txContextKey{}).(*txContext); ok {
+		return txc.byKey[key]
+	}
+	return nil
+}
+
+// Conn 返回 context 中的事务，没有事务时返回数据源连接，均绑定 ctx
+func Conn(ctx context.Context, key string) *gorm.DB {
+	if tx, ok := TxFromContext(ctx, key); ok {
+		return tx.WithContext(ctx)
+	}
+	if key == "" {
+		key = "*"
+	}
+	db := GetDb(key)
+	if db == nil {
+		return nil
+	}
+	return db.WithContext(ctx)
+}
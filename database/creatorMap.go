@@ -1,12 +1,15 @@
 package database
 
 import (
+	"github.com/trancecho/open-sdk/config"
 	"github.com/trancecho/open-sdk/database/driver"
 	"gorm.io/gorm"
 )
 
 type Creator interface {
-	Create(ip string, port string, userName string, password string, dbName string) (*gorm.DB, error)
+	Create(conf config.Datasource) (*gorm.DB, error)
+	// Dialector 只构造方言不建立连接，用于为数据源挂载只读副本
+	Dialector(conf config.Datasource) (gorm.Dialector, error)
 }
 
 type DbModel interface {
@@ -15,6 +18,9 @@ type DbModel interface {
 
 func init() {
 	typeMap["mysql"] = driver.MySQLCreator{}
+	typeMap["postgres"] = driver.PostgresCreator{}
+	typeMap["sqlite"] = driver.SQLiteCreator{}
+	typeMap["sqlserver"] = driver.SQLServerCreator{}
 }
 
 var typeMap = make(map[string]Creator)
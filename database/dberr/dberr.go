@@ -0,0 +1,80 @@
+package dberr
+
+import (
+	"context"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
+	"gorm.io/gorm"
+)
+
+// 错误分类，用于日志和监控
+const (
+	ClassNone      = ""
+	ClassNotFound  = "not_found"
+	ClassDuplicate = "duplicate"
+	ClassConflict  = "conflict" // 死锁、锁等待超时、序列化冲突
+	ClassTimeout   = "timeout"
+	ClassCanceled  = "canceled"
+	ClassOther     = "other"
+)
+
+// Classify 对数据库错误分类，识别 database 包支持的所有驱动
+func Classify(err error) string {
+	switch {
+	case err == nil:
+		return ClassNone
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ClassNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case IsDuplicate(err):
+		return ClassDuplicate
+	case IsRetryable(err):
+		return ClassConflict
+	}
+	return ClassOther
+}
+
+// IsRetryable 判断错误是否为死锁或序列化冲突，重新执行整个事务通常可以成功
+func IsRetryable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// 1213 死锁，1205 锁等待超时
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 40001 serialization_failure，40P01 deadlock_detected
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		// 1205 被选为死锁牺牲品
+		return mssqlErr.Number == 1205
+	}
+	return sqliteRetryable(err)
+}
+
+// IsDuplicate 判断错误是否为唯一键冲突
+func IsDuplicate(err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var mssqlErr mssql.Error
+	if errors.As(err, &mssqlErr) {
+		return mssqlErr.Number == 2627 || mssqlErr.Number == 2601
+	}
+	return sqliteDuplicate(err)
+}
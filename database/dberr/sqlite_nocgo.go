@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package dberr
+
+// 未启用 cgo 时 go-sqlite3 不可用，不会产生 sqlite 错误
+
+func sqliteRetryable(error) bool {
+	return false
+}
+
+func sqliteDuplicate(error) bool {
+	return false
+}
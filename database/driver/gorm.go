@@ -0,0 +1,82 @@
+package driver
+
+import (
+	"fmt"
+	"github.com/trancecho/open-sdk/config"
+	"github.com/trancecho/open-sdk/database/sqllog"
+	"gorm.io/gorm"
+	"net/url"
+	"time"
+)
+
+// dbSystems 数据源类型对应的 OpenTelemetry db.system
+var dbSystems = map[string]string{
+	"mysql":     "mysql",
+	"postgres":  "postgresql",
+	"sqlite":    "sqlite",
+	"sqlserver": "mssql",
+}
+
+// open 打开 gorm 连接并应用数据源中的日志和连接池配置，各驱动共用。
+// 审计、租户等业务插件由 database 包在创建数据源时挂载
+func open(dialector gorm.Dialector, conf config.Datasource) (*gorm.DB, error) {
+	gormConfig, err := newGormConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if conf.Trace {
+		if err = db.Use(sqllog.TracingPlugin{Datasource: conf.Key, System: dbSystems[conf.Type]}); err != nil {
+			_ = sqlDB.Close()
+			return nil, err
+		}
+	}
+	if conf.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(conf.MaxOpenConns)
+	}
+	if conf.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(conf.MaxIdleConns)
+	}
+	if conf.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	}
+	if conf.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+	}
+	return db, nil
+}
+
+func newGormConfig(conf config.Datasource) (*gorm.Config, error) {
+	level, err := sqllog.ParseLevel(conf.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("datasource %s: %w", conf.Key, err)
+	}
+	return &gorm.Config{
+		Logger: sqllog.New(conf.Key, level, conf.SlowThreshold, conf.RedactParams),
+	}, nil
+}
+
+// mergeParams 用数据源的 Params 覆盖驱动默认的 DSN 参数
+func mergeParams(defaults url.Values, conf config.Datasource) (url.Values, error) {
+	params, err := url.ParseQuery(conf.Params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Params for datasource %s: %w", conf.Key, err)
+	}
+	for k, v := range params {
+		defaults[k] = v
+	}
+	return defaults, nil
+}
+
+// seconds 将超时转换为整数秒，不足一秒按一秒计
+func seconds(d time.Duration) string {
+	s := int((d + time.Second - 1) / time.Second)
+	return fmt.Sprint(s)
+}
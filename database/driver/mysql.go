@@ -2,30 +2,45 @@ package driver
 
 import (
 	"fmt"
+	"github.com/trancecho/open-sdk/config"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-	"log"
-	"os"
-	"time"
+	"net/url"
 )
 
 type MySQLCreator struct{}
 
-func (m MySQLCreator) Create(ip string, port string, userName string, password string, dbName string) (*gorm.DB, error) {
-	newLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-		logger.Config{
-			SlowThreshold:             time.Second,   // 慢 SQL 阈值
-			LogLevel:                  logger.Silent, // 日志级别
-			IgnoreRecordNotFoundError: true,          // 忽略ErrRecordNotFound（记录未找到）错误
-			Colorful:                  false,         // 禁用彩色打印
-		},
-	)
-	var dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		userName, password, ip, port, dbName)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: newLogger,
-	})
-	return db, err
+func (m MySQLCreator) Create(conf config.Datasource) (*gorm.DB, error) {
+	dialector, err := m.Dialector(conf)
+	if err != nil {
+		return nil, err
+	}
+	return open(dialector, conf)
+}
+
+func (m MySQLCreator) Dialector(conf config.Datasource) (gorm.Dialector, error) {
+	defaults := url.Values{
+		"charset":   {"utf8mb4"},
+		"parseTime": {"True"},
+		"loc":       {"Local"},
+	}
+	if conf.ConnectTimeout > 0 {
+		defaults.Set("timeout", conf.ConnectTimeout.String())
+	}
+	if conf.ReadTimeout > 0 {
+		defaults.Set("readTimeout", conf.ReadTimeout.String())
+	}
+	if conf.WriteTimeout > 0 {
+		defaults.Set("writeTimeout", conf.WriteTimeout.String())
+	}
+	if conf.TLS != "" {
+		defaults.Set("tls", conf.TLS)
+	}
+	params, err := mergeParams(defaults, conf)
+	if err != nil {
+		return nil, err
+	}
+	var dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s",
+		conf.USER, conf.PASSWORD, conf.IP, conf.PORT, conf.DATABASE, params.Encode())
+	return mysql.Open(dsn), nil
 }
@@ -0,0 +1,41 @@
+package driver
+
+import (
+	"fmt"
+	"github.com/trancecho/open-sdk/config"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"net/url"
+)
+
+type PostgresCreator struct{}
+
+func (p PostgresCreator) Create(conf config.Datasource) (*gorm.DB, error) {
+	dialector, err := p.Dialector(conf)
+	if err != nil {
+		return nil, err
+	}
+	return open(dialector, conf)
+}
+
+func (p PostgresCreator) Dialector(conf config.Datasource) (gorm.Dialector, error) {
+	defaults := url.Values{"sslmode": {"disable"}}
+	if conf.TLS != "" {
+		defaults.Set("sslmode", conf.TLS)
+	}
+	if conf.ConnectTimeout > 0 {
+		defaults.Set("connect_timeout", seconds(conf.ConnectTimeout))
+	}
+	params, err := mergeParams(defaults, conf)
+	if err != nil {
+		return nil, err
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(conf.USER, conf.PASSWORD),
+		Host:     fmt.Sprintf("%s:%s", conf.IP, conf.PORT),
+		Path:     "/" + conf.DATABASE,
+		RawQuery: params.Encode(),
+	}
+	return postgres.Open(dsn.String()), nil
+}
@@ -0,0 +1,27 @@
+package driver
+
+import (
+	"github.com/trancecho/open-sdk/config"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// SQLiteCreator 使用 Database 作为数据库文件路径，":memory:" 为内存库，其余连接字段忽略
+// Params 原样追加到文件路径后，如 _busy_timeout=5000&_journal_mode=WAL
+type SQLiteCreator struct{}
+
+func (s SQLiteCreator) Create(conf config.Datasource) (*gorm.DB, error) {
+	dialector, err := s.Dialector(conf)
+	if err != nil {
+		return nil, err
+	}
+	return open(dialector, conf)
+}
+
+func (s SQLiteCreator) Dialector(conf config.Datasource) (gorm.Dialector, error) {
+	dsn := conf.DATABASE
+	if conf.Params != "" {
+		dsn += "?" + conf.Params
+	}
+	return sqlite.Open(dsn), nil
+}
@@ -0,0 +1,40 @@
+package driver
+
+import (
+	"fmt"
+	"github.com/trancecho/open-sdk/config"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+	"net/url"
+)
+
+type SQLServerCreator struct{}
+
+func (s SQLServerCreator) Create(conf config.Datasource) (*gorm.DB, error) {
+	dialector, err := s.Dialector(conf)
+	if err != nil {
+		return nil, err
+	}
+	return open(dialector, conf)
+}
+
+func (s SQLServerCreator) Dialector(conf config.Datasource) (gorm.Dialector, error) {
+	defaults := url.Values{"database": {conf.DATABASE}}
+	if conf.TLS != "" {
+		defaults.Set("encrypt", conf.TLS)
+	}
+	if conf.ConnectTimeout > 0 {
+		defaults.Set("dial timeout", seconds(conf.ConnectTimeout))
+	}
+	params, err := mergeParams(defaults, conf)
+	if err != nil {
+		return nil, err
+	}
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(conf.USER, conf.PASSWORD),
+		Host:     fmt.Sprintf("%s:%s", conf.IP, conf.PORT),
+		RawQuery: params.Encode(),
+	}
+	return sqlserver.Open(dsn.String()), nil
+}
@@ -0,0 +1,173 @@
+package fieldcrypt
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	callbackKey = "fieldcrypt"
+	// blindIndexTag 盲索引列的 gorm 标签，值为明文来源字段名
+	//
+	//	Phone    string `gorm:"serializer:encrypted;size:255"`
+	//	PhoneIdx string `gorm:"size:32;index;blindindex:Phone"`
+	blindIndexTag = "BLINDINDEX"
+)
+
+// companion 盲索引列及其来源字段
+type companion struct {
+	index  *schema.Field
+	source *schema.Field
+}
+
+var companions sync.Map // *schema.Schema => []companion
+
+func companionsOf(s *schema.Schema) []companion {
+	if v, ok := companions.Load(s); ok {
+		return v.([]companion)
+	}
+	var list []companion
+	for _, field := range s.Fields {
+		name, ok := field.TagSettings[blindIndexTag]
+		if !ok || field.DBName == "" {
+			continue
+		}
+		if source := s.LookUpField(name); source != nil {
+			list = append(list, companion{index: field, source: source})
+		}
+	}
+	companions.Store(s, list)
+	return list
+}
+
+// Plugin 写入时根据来源字段的明文填充 blindindex 标签标记的盲索引列，数据库驱动创建连接时自动启用
+type Plugin struct{}
+
+func New() *Plugin {
+	return &Plugin{}
+}
+
+func (p *Plugin) Name() string {
+	return "open-sdk:fieldcrypt"
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register(callbackKey+":create", p.create); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register(callbackKey+":update", p.update)
+}
+
+func (p *Plugin) create(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	for _, c := range companionsOf(stmt.Schema) {
+		set := func(rv reflect.Value) {
+			idx, err := indexOf(c, rawValue(stmt.Context, c.source, rv))
+			if err == nil {
+				err = c.index.Set(stmt.Context, rv, idx)
+			}
+			if err != nil {
+				_ = db.AddError(err)
+			}
+		}
+		switch rv := stmt.ReflectValue; rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				if elem := reflect.Indirect(rv.Index(i)); elem.Kind() == reflect.Struct {
+					set(elem)
+				}
+			}
+		case reflect.Struct:
+			set(rv)
+		}
+	}
+}
+
+func (p *Plugin) update(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	list := companionsOf(stmt.Schema)
+	if len(list) == 0 {
+		return
+	}
+	selectColumns, restricted := stmt.SelectAndOmitColumns(false, true)
+	for _, c := range list {
+		var (
+			value   any
+			updated bool
+		)
+		switch dest := stmt.Dest.(type) {
+		case map[string]any:
+			if value, updated = dest[c.source.DBName]; !updated {
+				value, updated = dest[c.source.Name]
+			}
+		default:
+			rv := reflect.Indirect(reflect.ValueOf(dest))
+			if rv.Kind() != reflect.Struct || rv.Type() != stmt.Schema.ModelType {
+				continue
+			}
+			fv := c.source.ReflectValueOf(stmt.Context, rv)
+			value = fv.Interface()
+			updated = (restricted && selectColumns[c.source.DBName]) || (!restricted && !fv.IsZero())
+		}
+		if !updated {
+			continue
+		}
+		idx, err := indexOf(c, value)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		stmt.SetColumn(c.index.DBName, idx, true)
+		if restricted && !selectColumns[c.index.DBName] {
+			stmt.Selects = append(stmt.Selects, c.index.DBName)
+		}
+	}
+}
+
+// rawValue 取字段的原始值，serializer 字段的 ValueOf 返回的是序列化包装而不是明文
+func rawValue(ctx context.Context, field *schema.Field, rv reflect.Value) any {
+	return field.ReflectValueOf(ctx, rv).Interface()
+}
+
+// indexOf 计算来源字段值的盲索引，空值对应空索引
+func indexOf(c companion, v any) (string, error) {
+	plain, ok, err := plainBytes(v)
+	if err != nil || !ok || len(plain) == 0 {
+		return "", err
+	}
+	k, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return k.BlindIndex(c.index.DBName, string(plain))
+}
+
+// WhereBlind 按盲索引列等值查询的 scope，value 为来源字段的明文
+//
+//	db.Scopes(fieldcrypt.WhereBlind("phone_idx", "13800000000")).First(&user)
+func WhereBlind(column, value string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		k, err := Default()
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		idx, err := k.BlindIndex(column, value)
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: idx})
+	}
+}
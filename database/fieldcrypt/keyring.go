@@ -0,0 +1,182 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/trancecho/open-sdk/config"
+)
+
+var (
+	// ErrNoKey 未配置字段加密密钥
+	ErrNoKey = errors.New("field encryption key not configured")
+	// ErrUnknownVersion 密文使用的密钥版本不在配置中
+	ErrUnknownVersion = errors.New("unknown field encryption key version")
+)
+
+// Keyring 字段加密密钥环，密文格式为 v<版本>:<base64(nonce+密文)>
+type Keyring struct {
+	keys     map[int]cipher.AEAD
+	active   int
+	blindKey []byte
+}
+
+// NewKeyring 根据配置创建密钥环
+func NewKeyring(conf config.FieldEncryption) (*Keyring, error) {
+	k := &Keyring{keys: make(map[int]cipher.AEAD), active: conf.ActiveVersion}
+	for _, fk := range conf.Keys {
+		if fk.Version <= 0 {
+			return nil, fmt.Errorf("field encryption key version must be positive, got %d", fk.Version)
+		}
+		if _, ok := k.keys[fk.Version]; ok {
+			return nil, fmt.Errorf("duplicate field encryption key version %d", fk.Version)
+		}
+		raw, err := config.ParseSecretKey(fk.Key)
+		if err != nil {
+			return nil, fmt.Errorf("field encryption key version %d: %w", fk.Version, err)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		if k.keys[fk.Version], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+		if conf.ActiveVersion == 0 && fk.Version > k.active {
+			k.active = fk.Version
+		}
+	}
+	if _, ok := k.keys[k.active]; !ok && len(k.keys) > 0 {
+		return nil, fmt.Errorf("%w: active version %d", ErrUnknownVersion, k.active)
+	}
+	if conf.BlindIndexKey != "" {
+		var err error
+		if k.blindKey, err = config.ParseSecretKey(conf.BlindIndexKey); err != nil {
+			return nil, fmt.Errorf("blind index key: %w", err)
+		}
+	}
+	return k, nil
+}
+
+// ActiveVersion 加密新数据使用的密钥版本
+func (k *Keyring) ActiveVersion() int {
+	return k.active
+}
+
+// Encrypt 使用当前版本密钥加密，aad 为附加认证数据，解密时必须一致
+func (k *Keyring) Encrypt(plain []byte, aad string) (string, error) {
+	gcm, ok := k.keys[k.active]
+	if !ok {
+		return "", ErrNoKey
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, []byte(aad))
+	return "v" + strconv.Itoa(k.active) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 按密文中的版本选择密钥解密
+func (k *Keyring) Decrypt(value string, aad string) ([]byte, error) {
+	version, body, ok := ParseVersion(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid encrypted value")
+	}
+	gcm, ok := k.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(body)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted value")
+	}
+	nonce, data := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, data, []byte(aad))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt field: %w", err)
+	}
+	return plain, nil
+}
+
+// BlindIndex 计算 value 在盲索引列 column 中的值：以列名派生的 HMAC-SHA256，取前 16 字节的十六进制
+// 不同列的相同明文得到不同的索引值
+func (k *Keyring) BlindIndex(column, value string) (string, error) {
+	if k.blindKey == nil {
+		return "", fmt.Errorf("%w: blind index key", ErrNoKey)
+	}
+	derive := hmac.New(sha256.New, k.blindKey)
+	derive.Write([]byte(column))
+	mac := hmac.New(sha256.New, derive.Sum(nil))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16]), nil
+}
+
+// ParseVersion 解析 v<版本>: 前缀，不是密文时 ok 为 false
+func ParseVersion(value string) (version int, body string, ok bool) {
+	prefix, body, found := strings.Cut(value, ":")
+	if !found || len(prefix) < 2 || prefix[0] != 'v' {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(prefix[1:])
+	if err != nil || version <= 0 {
+		return 0, "", false
+	}
+	return version, body, true
+}
+
+var (
+	keyring    *Keyring
+	loadedFrom *config.GlobalConfig // 密钥环来源的配置，SetKeyring 设置时为 nil
+	mux        sync.RWMutex
+)
+
+// SetKeyring 设置全局密钥环，设置后不再随配置重新加载；
+// 未设置时从 config.GetConfig().FieldEncryption 加载，配置热更新后自动重建
+func SetKeyring(k *Keyring) {
+	mux.Lock()
+	defer mux.Unlock()
+	keyring, loadedFrom = k, nil
+}
+
+// Default 返回全局密钥环
+func Default() (*Keyring, error) {
+	conf := config.GetConfig()
+	mux.RLock()
+	k, from := keyring, loadedFrom
+	mux.RUnlock()
+	if k != nil && (from == nil || from == conf) {
+		return k, nil
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if keyring != nil && (loadedFrom == nil || loadedFrom == conf) {
+		return keyring, nil
+	}
+	if conf == nil {
+		return nil, ErrNoKey
+	}
+	k, err := NewKeyring(conf.FieldEncryption)
+	if err != nil {
+		if keyring == nil {
+			return nil, err
+		}
+		// 新配置中的密钥无效时继续使用旧密钥环，避免热更新出错导致读写全部失败
+		log.Println("fieldcrypt: reload keyring failed, keep previous keys:", err)
+		loadedFrom = conf
+		return keyring, nil
+	}
+	keyring, loadedFrom = k, conf
+	return keyring, nil
+}
@@ -0,0 +1,130 @@
+package fieldcrypt
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reencrypt 分批扫描 model 对应的表：把旧版本密钥或明文存储的加密列用当前密钥重新加密，
+// 并重建不一致的盲索引（如更换了 BlindIndexKey）。返回更新的行数，可重复执行。
+// 按主键逐行更新且以旧值为条件，与并发写入冲突的行会被跳过，再次执行即可
+func Reencrypt(ctx context.Context, db *gorm.DB, model any, batchSize int) (int64, error) {
+	k, err := Default()
+	if err != nil {
+		return 0, err
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err = stmt.Parse(model); err != nil {
+		return 0, err
+	}
+	s := stmt.Schema
+	pk := s.PrioritizedPrimaryField
+	if pk == nil {
+		return 0, fmt.Errorf("reencrypt %s requires a single primary key", s.Table)
+	}
+	var encrypted []string
+	for _, field := range s.Fields {
+		if field.DBName != "" && field.TagSettings["SERIALIZER"] == SerializerName {
+			encrypted = append(encrypted, field.DBName)
+		}
+	}
+	list := companionsOf(s)
+	columns := append([]string{pk.DBName}, encrypted...)
+	for _, c := range list {
+		columns = append(columns, c.index.DBName, c.source.DBName)
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	isEncrypted := make(map[string]bool, len(encrypted))
+	for _, column := range encrypted {
+		isEncrypted[column] = true
+	}
+	// plain 解出列的明文，非密文按明文处理
+	plain := func(column string, raw string) ([]byte, error) {
+		if _, _, ok := ParseVersion(raw); !ok || !isEncrypted[column] {
+			return []byte(raw), nil
+		}
+		return k.Decrypt(raw, s.Table+"."+column)
+	}
+
+	var (
+		updated int64
+		last    any
+	)
+	session := db.Session(&gorm.Session{NewDB: true}).WithContext(ctx)
+	for {
+		var rows []map[string]any
+		query := session.Table(s.Table).Select(columns).Order(clause.OrderByColumn{Column: clause.Column{Name: pk.DBName}}).Limit(batchSize)
+		if last != nil {
+			query = query.Where(clause.Gt{Column: clause.Column{Name: pk.DBName}, Value: last})
+		}
+		if err = query.Find(&rows).Error; err != nil {
+			return updated, err
+		}
+		for _, row := range rows {
+			changes, conds := make(map[string]any), make(map[string]any)
+			for _, column := range encrypted {
+				raw := text(row[column])
+				if raw == "" {
+					continue
+				}
+				if version, _, ok := ParseVersion(raw); ok && version == k.ActiveVersion() {
+					continue
+				}
+				value, err := plain(column, raw)
+				if err != nil {
+					return updated, fmt.Errorf("%s %v: %w", s.Table, row[pk.DBName], err)
+				}
+				if changes[column], err = k.Encrypt(value, s.Table+"."+column); err != nil {
+					return updated, err
+				}
+				conds[column] = raw
+			}
+			for _, c := range list {
+				value, err := plain(c.source.DBName, text(row[c.source.DBName]))
+				if err != nil {
+					return updated, fmt.Errorf("%s %v: %w", s.Table, row[pk.DBName], err)
+				}
+				idx := ""
+				if len(value) > 0 {
+					if idx, err = k.BlindIndex(c.index.DBName, string(value)); err != nil {
+						return updated, err
+					}
+				}
+				if idx != text(row[c.index.DBName]) {
+					changes[c.index.DBName] = idx
+				}
+			}
+			if len(changes) == 0 {
+				continue
+			}
+			conds[pk.DBName] = row[pk.DBName]
+			result := session.Table(s.Table).Where(conds).Updates(changes)
+			if result.Error != nil {
+				return updated, result.Error
+			}
+			updated += result.RowsAffected
+		}
+		if len(rows) < batchSize {
+			return updated, nil
+		}
+		last = rows[len(rows)-1][pk.DBName]
+	}
+}
+
+func text(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,114 @@
+package fieldcrypt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName 字段加密的 gorm serializer 名称
+//
+//	Phone string `gorm:"serializer:encrypted;size:255"`
+const SerializerName = "encrypted"
+
+func init() {
+	schema.RegisterSerializer(SerializerName, Serializer{})
+}
+
+// Serializer 使用全局密钥环加解密字段，附加认证数据为 表名.列名，密文不能在列之间挪用。
+// string 和 []byte 字段直接加密，其他类型先序列化为 JSON。
+// 空字符串和 nil 不加密；没有版本前缀的旧数据按明文读取，可用 Reencrypt 批量加密
+type Serializer struct{}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	fieldValue := reflect.New(field.FieldType)
+	var raw string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("unsupported encrypted value type %T for %s", dbValue, field.Name)
+	}
+	if raw != "" {
+		plain := []byte(raw)
+		if _, _, ok := ParseVersion(raw); ok {
+			k, err := Default()
+			if err != nil {
+				return err
+			}
+			if plain, err = k.Decrypt(raw, aad(field)); err != nil {
+				return fmt.Errorf("%s: %w", field.Name, err)
+			}
+		}
+		if err := setPlain(fieldValue.Elem(), plain); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+	plain, ok, err := plainBytes(fieldValue)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if len(plain) == 0 {
+		return "", nil
+	}
+	k, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return k.Encrypt(plain, aad(field))
+}
+
+func aad(field *schema.Field) string {
+	return field.Schema.Table + "." + field.DBName
+}
+
+// plainBytes 取字段的明文字节，nil 指针时 ok 为 false
+func plainBytes(v any) ([]byte, bool, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false, nil
+		}
+		rv = rv.Elem()
+	}
+	switch {
+	case !rv.IsValid():
+		return nil, false, nil
+	case rv.Kind() == reflect.String:
+		return []byte(rv.String()), true, nil
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		if rv.IsNil() {
+			return nil, false, nil
+		}
+		return rv.Bytes(), true, nil
+	}
+	data, err := json.Marshal(rv.Interface())
+	return data, err == nil, err
+}
+
+func setPlain(rv reflect.Value, plain []byte) error {
+	if rv.Kind() == reflect.Ptr {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		rv = rv.Elem()
+	}
+	switch {
+	case rv.Kind() == reflect.String:
+		rv.SetString(string(plain))
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		rv.SetBytes(append([]byte(nil), plain...))
+	default:
+		return json.Unmarshal(plain, rv.Addr().Interface())
+	}
+	return nil
+}
@@ -1,10 +1,24 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/trancecho/open-sdk/config"
+	"github.com/trancecho/open-sdk/database/audit"
+	"github.com/trancecho/open-sdk/database/fieldcrypt"
+	"github.com/trancecho/open-sdk/database/tenant"
 	"gorm.io/gorm"
 	"log"
 	"sync"
+	"time"
+)
+
+const (
+	defaultConnectRetries = 3
+	defaultRetryBackoff   = time.Second
+	maxRetryBackoff       = 30 * time.Second
 )
 
 var (
@@ -12,47 +26,219 @@ var (
 	mux sync.RWMutex
 )
 
-func InitDB() {
+// InitDB 按配置创建数据源，keys 为空时创建全部，否则只创建指定的数据源。
+// 连接失败时按退避策略重试，全部成功返回 nil，
+// 某个数据源最终失败不会影响其余数据源的创建，错误会合并返回
+func InitDB(keys ...string) error {
+	var errs []error
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[key] = true
+	}
 	sources := config.GetConfig().Databases
 	for _, source := range sources {
-		setDbByKey(source.Key, mustCreateGorm(source))
 		if source.Key == "" {
 			source.Key = "*"
 		}
+		if len(keys) > 0 && !wanted[source.Key] {
+			continue
+		}
+		delete(wanted, source.Key)
+		db, err := createGormWithRetry(source)
+		if err == nil {
+			if err = setDbByKey(source.Key, db); err != nil {
+				closeGorm(db)
+			}
+		}
+		if err != nil {
+			log.Println("create datasource", source.Key, "failed:", err)
+			errs = append(errs, fmt.Errorf("datasource %s: %w", source.Key, err))
+			continue
+		}
 		//logx.NameSpace("Dbx").Infoln("create datasource %s => %s:%s", source.Key, source.IP, source.PORT)
 		log.Println("create datasource", source.Key, "=>", source.IP, ":", source.PORT)
 	}
+	for key := range wanted {
+		errs = append(errs, fmt.Errorf("datasource %s not configured", key))
+	}
+	return errors.Join(errs...)
 }
 
 func GetDb(key string) *gorm.DB {
-	mux.Lock()
-	defer mux.Unlock()
+	mux.RLock()
+	defer mux.RUnlock()
 	return dbs[key]
 }
 
-func setDbByKey(key string, db *gorm.DB) {
+// Keys 返回所有已注册的数据源 key
+func Keys() []string {
+	mux.RLock()
+	defer mux.RUnlock()
+	keys := make([]string, 0, len(dbs))
+	for key := range dbs {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Ping 检查所有数据源的主库和副本连接，返回合并后的错误
+func Ping(ctx context.Context) error {
+	var errs []error
+	for key, db := range snapshot() {
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.PingContext(ctx)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("datasource %s: %w", key, err))
+		}
+		for i, pool := range replicaPools(db) {
+			if pg, ok := pool.(pinger); ok {
+				if err = pg.PingContext(ctx); err != nil {
+					errs = append(errs, fmt.Errorf("datasource %s replica %d: %w", key, i, err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats 返回每个数据源连接池的统计信息，副本以 <key>/replica-<n> 为键
+func Stats() map[string]sql.DBStats {
+	stats := make(map[string]sql.DBStats)
+	for key, db := range snapshot() {
+		if sqlDB, err := db.DB(); err == nil {
+			stats[key] = sqlDB.Stats()
+		}
+		for i, pool := range replicaPools(db) {
+			if sqlDB, ok := pool.(*sql.DB); ok {
+				stats[fmt.Sprintf("%s/replica-%d", key, i)] = sqlDB.Stats()
+			}
+		}
+	}
+	return stats
+}
+
+// CloseAll 关闭并注销所有数据源，用于优雅退出
+func CloseAll() error {
+	mux.Lock()
+	closing := dbs
+	dbs = make(map[string]*gorm.DB)
+	mux.Unlock()
+
+	var errs []error
+	for key, db := range closing {
+		if err := closeSources(db); err != nil {
+			errs = append(errs, fmt.Errorf("datasource %s: %w", key, err))
+			continue
+		}
+		log.Println("close datasource", key)
+	}
+	return errors.Join(errs...)
+}
+
+func snapshot() map[string]*gorm.DB {
+	mux.RLock()
+	defer mux.RUnlock()
+	m := make(map[string]*gorm.DB, len(dbs))
+	for key, db := range dbs {
+		m[key] = db
+	}
+	return m
+}
+
+func setDbByKey(key string, db *gorm.DB) error {
 	if key == "" {
 		key = "*"
 	}
-	if GetDb(key) != nil {
-		log.Fatalln("duplicate db key: ", key)
-	}
 	mux.Lock()
 	defer mux.Unlock()
+	if dbs[key] != nil {
+		return fmt.Errorf("duplicate db key: %s", key)
+	}
 	dbs[key] = db
+	return nil
 }
 
-func mustCreateGorm(database config.Datasource) *gorm.DB {
+func createGormWithRetry(database config.Datasource) (*gorm.DB, error) {
+	retries := database.ConnectRetries
+	if retries == 0 {
+		retries = defaultConnectRetries
+	}
+	backoff := database.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		db, err := createGorm(database)
+		if err == nil || attempt >= retries || errors.Is(err, errUnknownType) {
+			return db, err
+		}
+		log.Println("connect datasource", database.Key, "failed, retry in", backoff, ":", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+var errUnknownType = errors.New("fail to find creator for types")
+
+func createGorm(database config.Datasource) (*gorm.DB, error) {
 	var creator = getCreatorByType(database.Type)
 	if creator == nil {
-		log.Fatalln("fail to find creator for types:", database.Type)
-		return nil
+		return nil, fmt.Errorf("%w: %s", errUnknownType, database.Type)
 	}
-	db, err := creator.Create(database.IP, database.PORT, database.USER, database.PASSWORD, database.DATABASE)
+	db, err := creator.Create(database)
 	if err != nil {
-		log.Fatalln(err)
-		return nil
+		return nil, err
+	}
+	if err = usePlugins(db, database); err != nil {
+		closeGorm(db)
+		return nil, err
 	}
+	if len(database.Replicas) > 0 {
+		if err = useReplicas(db, creator, database); err != nil {
+			closeGorm(db)
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// usePlugins 按数据源配置挂载字段加密、多租户和审计插件
+func usePlugins(db *gorm.DB, source config.Datasource) error {
+	if err := db.Use(fieldcrypt.New()); err != nil {
+		return err
+	}
+	if source.Tenant {
+		if err := db.Use(tenant.New()); err != nil {
+			return err
+		}
+	}
+	if source.Audit {
+		if err := db.Use(audit.New()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeGorm(db *gorm.DB) {
+	_ = closeSources(db)
+}
 
-	return db
+// closeSources 关闭主库和所有副本的连接池
+func closeSources(db *gorm.DB) error {
+	var errs []error
+	for _, pool := range replicaPools(db) {
+		if c, ok := pool.(interface{ Close() error }); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.Close()
+	}
+	return errors.Join(append(errs, err)...)
 }
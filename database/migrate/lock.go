@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const lockID = 1
+
+// migrationLock 单行锁表，插入成功即持有锁，适用于所有驱动
+type migrationLock struct {
+	ID       int    `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string `gorm:"size:255"`
+	LockedAt time.Time
+}
+
+func (m *Migrator) lockTable() string {
+	return m.table + "_lock"
+}
+
+// withLock 获取迁移锁后执行 fn，防止多个实例同时迁移。
+// 执行期间定期续期，锁被抢占时取消 fn 的 ctx
+func (m *Migrator) withLock(ctx context.Context, fn func(db *gorm.DB) error) error {
+	db := m.db.WithContext(ctx)
+	if err := m.ensureTables(db); err != nil {
+		return err
+	}
+	owner := lockOwner()
+	if err := m.acquire(ctx, db, owner); err != nil {
+		return err
+	}
+	defer func() {
+		// 使用独立 context，确保调用方取消后锁仍能释放
+		m.db.Table(m.lockTable()).Where("id = ? AND owner = ?", lockID, owner).Delete(&migrationLock{})
+	}()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go m.heartbeat(owner, done, cancel)
+	return fn(m.db.WithContext(ctx))
+}
+
+// heartbeat 每 staleLock/3 更新一次 locked_at，直到 done 关闭
+func (m *Migrator) heartbeat(owner string, done <-chan struct{}, lost context.CancelFunc) {
+	ticker := time.NewTicker(max(m.staleLock/3, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		result := m.db.Table(m.lockTable()).Where("id = ? AND owner = ?", lockID, owner).
+			Update("locked_at", time.Now())
+		if result.Error != nil {
+			log.Println("renew migration lock failed:", result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			log.Println("migration lock lost, aborting")
+			lost()
+			return
+		}
+	}
+}
+
+func (m *Migrator) acquire(ctx context.Context, db *gorm.DB, owner string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+	defer cancel()
+	for {
+		lock := migrationLock{ID: lockID, Owner: owner, LockedAt: time.Now()}
+		if err := db.Table(m.lockTable()).Create(&lock).Error; err == nil {
+			return nil
+		}
+		// 持有者崩溃时锁不会释放，超过 staleLock 后抢占
+		db.Table(m.lockTable()).Where("id = ? AND locked_at < ?", lockID, time.Now().Add(-m.staleLock)).
+			Delete(&migrationLock{})
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("acquire migration lock: %w", ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
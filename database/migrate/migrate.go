@@ -0,0 +1,294 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const defaultTable = "schema_migrations"
+
+// ErrDirty 上次迁移中途失败，需要人工修复后执行 Force
+var ErrDirty = errors.New("database is dirty, fix it and run force")
+
+// schemaMigration 每个已执行的迁移一行，Dirty 表示执行未完成
+type schemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db          *gorm.DB
+	table       string
+	migrations  []Migration
+	lockTimeout time.Duration
+	staleLock   time.Duration
+}
+
+type Option func(*Migrator)
+
+// New 创建迁移器，db 可以是 database 包中任意驱动创建的连接，配置了副本时所有语句都走主库
+func New(db *gorm.DB, opts ...Option) *Migrator {
+	m := &Migrator{
+		db:          db.Clauses(dbresolver.Write).Session(&gorm.Session{}),
+		table:       defaultTable,
+		lockTimeout: time.Minute,
+		staleLock:   15 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// WithMigrations 添加迁移，可多次使用
+func WithMigrations(migrations ...Migration) Option {
+	return func(m *Migrator) {
+		m.migrations = append(m.migrations, migrations...)
+	}
+}
+
+// WithTable 自定义版本表名，锁表名为 <table>_lock
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithLockTimeout 等待其他实例释放迁移锁的最长时间
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockTimeout = timeout
+	}
+}
+
+// WithStaleLock 超过该时长未续期的锁视为持有者已崩溃，可被抢占，持有者每 1/3 时长续期一次
+func WithStaleLock(d time.Duration) Option {
+	return func(m *Migrator) {
+		m.staleLock = d
+	}
+}
+
+// Up 执行所有未执行的迁移，返回本次执行的数量
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	migrations, err := m.sorted()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for _, mg := range migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err = m.run(db, mg, true); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down 按版本倒序回滚最近 n 个已执行的迁移，n 必须大于 0
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid down steps %d", n)
+	}
+	migrations, err := m.sorted()
+	if err != nil {
+		return 0, err
+	}
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, mg := range migrations {
+		byVersion[mg.Version] = mg
+	}
+	count := 0
+	err = m.withLock(ctx, func(db *gorm.DB) error {
+		if _, err := m.applied(db); err != nil {
+			return err
+		}
+		var rows []schemaMigration
+		if err := db.Table(m.table).Order("version desc").Limit(n).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			mg, ok := byVersion[row.Version]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but not found", row.Version)
+			}
+			if mg.Down == nil {
+				return fmt.Errorf("migration %d_%s has no down migration", mg.Version, mg.Name)
+			}
+			if err := m.run(db, mg, false); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status 返回所有已知迁移和已执行记录的状态，按版本升序
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, err := m.sorted()
+	if err != nil {
+		return nil, err
+	}
+	db := m.db.WithContext(ctx)
+	if err = m.ensureTables(db); err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err = db.Table(m.table).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	statuses := make(map[int64]*Status)
+	for _, mg := range migrations {
+		statuses[mg.Version] = &Status{Version: mg.Version, Name: mg.Name}
+	}
+	for _, row := range rows {
+		s, ok := statuses[row.Version]
+		if !ok {
+			s = &Status{Version: row.Version, Name: row.Name}
+			statuses[row.Version] = s
+		}
+		s.Applied, s.Dirty, s.AppliedAt = true, row.Dirty, row.AppliedAt
+	}
+	list := make([]Status, 0, len(statuses))
+	for _, s := range statuses {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Force 不执行迁移，直接把版本记录设置为 version：
+// 不大于 version 的迁移记为已执行且清除 dirty，大于 version 的记录被删除
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	migrations, err := m.sorted()
+	if err != nil {
+		return err
+	}
+	return m.withLock(ctx, func(db *gorm.DB) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table(m.table).Where("version > ?", version).Delete(&schemaMigration{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Table(m.table).Where("dirty = ?", true).Update("dirty", false).Error; err != nil {
+				return err
+			}
+			var existing []int64
+			if err := tx.Table(m.table).Pluck("version", &existing).Error; err != nil {
+				return err
+			}
+			seen := make(map[int64]bool, len(existing))
+			for _, v := range existing {
+				seen[v] = true
+			}
+			for _, mg := range migrations {
+				if mg.Version > version || seen[mg.Version] {
+					continue
+				}
+				row := schemaMigration{Version: mg.Version, Name: mg.Name, AppliedAt: time.Now()}
+				if err := tx.Table(m.table).Create(&row).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// run 先写入 dirty 记录再执行迁移，成功后清除 dirty（回滚时删除记录）
+// 对 MySQL 等 DDL 不能回滚的数据库，失败后记录保持 dirty 以便人工介入
+func (m *Migrator) run(db *gorm.DB, mg Migration, up bool) error {
+	direction, fn := "up", mg.Up
+	if !up {
+		direction, fn = "down", mg.Down
+	}
+	if up {
+		row := schemaMigration{Version: mg.Version, Name: mg.Name, Dirty: true, AppliedAt: time.Now()}
+		if err := db.Table(m.table).Create(&row).Error; err != nil {
+			return err
+		}
+	} else if err := db.Table(m.table).Where("version = ?", mg.Version).Update("dirty", true).Error; err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := db.Transaction(fn); err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %w", mg.Version, mg.Name, direction, err)
+	}
+	var err error
+	if up {
+		err = db.Table(m.table).Where("version = ?", mg.Version).
+			Updates(map[string]any{"dirty": false, "applied_at": time.Now()}).Error
+	} else {
+		err = db.Table(m.table).Where("version = ?", mg.Version).Delete(&schemaMigration{}).Error
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("migration %d_%s %s done in %s", mg.Version, mg.Name, direction, time.Since(start))
+	return nil
+}
+
+// applied 返回已执行的版本，存在 dirty 记录时返回 ErrDirty
+func (m *Migrator) applied(db *gorm.DB) (map[int64]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := db.Table(m.table).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		if row.Dirty {
+			return nil, fmt.Errorf("%w: version %d", ErrDirty, row.Version)
+		}
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) sorted() ([]Migration, error) {
+	migrations := append([]Migration(nil), m.migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, mg := range migrations {
+		if mg.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up migration", mg.Version, mg.Name)
+		}
+		if i > 0 && migrations[i-1].Version == mg.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", mg.Version)
+		}
+	}
+	return migrations, nil
+}
+
+func (m *Migrator) ensureTables(db *gorm.DB) error {
+	if err := db.Table(m.table).AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+	return db.Table(m.lockTable()).AutoMigrate(&migrationLock{})
+}
@@ -0,0 +1,180 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一个带版本号的迁移，Up/Down 在事务中执行
+// Down 为空时该迁移不可回滚
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadFS 从目录读取 SQL 迁移文件，文件名格式为 0001_create_users.up.sql / 0001_create_users.down.sql
+// 文件按分号拆分为多条语句逐条执行，引号和 $$ 内的分号不拆分；包含 BEGIN ... END
+// 过程体等无法可靠拆分的文件，在其中单独一行写 -- migrate:nosplit 使整个文件一次执行，
+// 此时需要驱动支持多语句（如 MySQL 的 multiStatements=true）
+// 可配合 embed.FS 使用：
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//	list, err := migrate.LoadFS(migrations, "migrations")
+func LoadFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has conflicting names %s and %s", version, m.Name, match[2])
+		}
+		run := sqlRunner(string(data))
+		if match[3] == "up" {
+			m.Up = run
+		} else {
+			m.Down = run
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// noSplitMarker 单独成行出现在文件中时整个文件作为一条语句执行，
+// 用于 MySQL 的 BEGIN ... END 存储过程、触发器等内部带分号且无法按引号识别的脚本
+const noSplitMarker = "-- migrate:nosplit"
+
+// sqlRunner 按语句拆分后逐条执行，不依赖驱动的多语句支持
+func sqlRunner(script string) func(tx *gorm.DB) error {
+	statements := splitStatements(script)
+	return func(tx *gorm.DB) error {
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("%w\n%s", err, stmt)
+			}
+		}
+		return nil
+	}
+}
+
+// splitStatements 按分号拆分语句并去掉 -- 注释。单引号、双引号、反引号字符串，
+// /* */ 注释和 PostgreSQL 的 $tag$ 美元引用内的分号不拆分。
+// 字符串内的反斜杠不视为转义，MySQL 中 'it\'s' 这类写法需改用两个单引号转义或使用 noSplitMarker
+func splitStatements(script string) []string {
+	for _, line := range strings.Split(script, "\n") {
+		if strings.TrimSpace(line) == noSplitMarker {
+			return []string{strings.TrimSpace(script)}
+		}
+	}
+	var (
+		statements []string
+		current    strings.Builder
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" && stmt != ";" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+	for i := 0; i < len(script); {
+		switch c := script[i]; {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+			continue
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			end = quotedEnd(script, i, end, 2+2)
+			current.WriteString(script[i:end])
+			i = end
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			// 连续两个引号是转义，整体上等价于两个相邻的字符串
+			end := strings.IndexByte(script[i+1:], c)
+			end = quotedEnd(script, i, end, 1+1)
+			current.WriteString(script[i:end])
+			i = end
+			continue
+		case c == '$':
+			if tag := dollarTag(script, i); tag != "" {
+				end := strings.Index(script[i+len(tag):], tag)
+				end = quotedEnd(script, i, end, len(tag)+len(tag))
+				current.WriteString(script[i:end])
+				i = end
+				continue
+			}
+		case c == ';':
+			current.WriteByte(c)
+			flush()
+			i++
+			continue
+		}
+		current.WriteByte(script[i])
+		i++
+	}
+	flush()
+	return statements
+}
+
+// quotedEnd 返回从 start 开始的引用段结束位置，offset 为在开始标记之后找到的结束标记偏移，
+// width 为开始与结束标记的总长度，未闭合时延伸到脚本末尾
+func quotedEnd(script string, start, offset, width int) int {
+	if offset < 0 {
+		return len(script)
+	}
+	return start + offset + width
+}
+
+var dollarTagPattern = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// dollarTag 返回 i 处的美元引用开始标记，如 $$、$body$，$1 等位置参数不是标记
+func dollarTag(script string, i int) string {
+	if i > 0 {
+		if p := script[i-1]; p == '_' || p >= '0' && p <= '9' || p >= 'A' && p <= 'Z' || p >= 'a' && p <= 'z' {
+			return ""
+		}
+	}
+	return dollarTagPattern.FindString(script[i:])
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			"lines",
+			"-- users\nCREATE TABLE users (\n  id INT\n);\n\nINSERT INTO users VALUES (1); INSERT INTO users VALUES (2);\n",
+			[]string{"CREATE TABLE users (\n  id INT\n);", "INSERT INTO users VALUES (1);", "INSERT INTO users VALUES (2);"},
+		},
+		{
+			"literals",
+			"INSERT INTO notes VALUES ('a;\n-- not a comment', 'it''s', \"b;\", `c;`);",
+			[]string{"INSERT INTO notes VALUES ('a;\n-- not a comment', 'it''s', \"b;\", `c;`);"},
+		},
+		{
+			"dollar quote",
+			"CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;\nSELECT $1, $body$;$body$;",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;", "SELECT $1, $body$;$body$;"},
+		},
+		{
+			"block comment",
+			"/* a; b */ SELECT 1; -- trailing;\n",
+			[]string{"/* a; b */ SELECT 1;"},
+		},
+		{
+			"no split",
+			"-- migrate:nosplit\nCREATE TRIGGER t BEFORE INSERT ON users FOR EACH ROW\nBEGIN\n  SET NEW.id = 1;\nEND;\n",
+			[]string{"-- migrate:nosplit\nCREATE TRIGGER t BEFORE INSERT ON users FOR EACH ROW\nBEGIN\n  SET NEW.id = 1;\nEND;"},
+		},
+	}
+	for _, tc := range cases {
+		if got := splitStatements(tc.script); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"sync"
+)
+
+var (
+	models   = make(map[string][]DbModel)
+	modelMux sync.RWMutex
+)
+
+// RegisterModel 按 DbKey 注册模型，通常在模型所在包的 init 中调用
+//
+//	func (User) DbKey() string { return "MainMysql" }
+//	func init() { database.RegisterModel(&User{}) }
+func RegisterModel(ms ...DbModel) {
+	modelMux.Lock()
+	defer modelMux.Unlock()
+	for _, m := range ms {
+		key := modelKey(m)
+		for _, registered := range models[key] {
+			if reflect.TypeOf(registered) == reflect.TypeOf(m) {
+				panic(fmt.Sprintf("database: model %T registered twice", m))
+			}
+		}
+		models[key] = append(models[key], m)
+	}
+}
+
+// Models 返回注册到某个数据源的模型
+func Models(key string) []DbModel {
+	if key == "" {
+		key = "*"
+	}
+	modelMux.RLock()
+	defer modelMux.RUnlock()
+	return append([]DbModel(nil), models[key]...)
+}
+
+// For 返回模型所属数据源的连接，数据源未初始化时返回 nil
+func For(model DbModel) *gorm.DB {
+	return GetDb(modelKey(model))
+}
+
+// AutoMigrate 对数据源执行已注册模型的 gorm AutoMigrate，不传 key 时处理所有已初始化的数据源
+func AutoMigrate(keys ...string) error {
+	if len(keys) == 0 {
+		keys = Keys()
+	}
+	var errs []error
+	for _, key := range keys {
+		db := GetDb(key)
+		if db == nil {
+			errs = append(errs, fmt.Errorf("datasource %s not found", key))
+			continue
+		}
+		ms := Models(key)
+		if len(ms) == 0 {
+			continue
+		}
+		dst := make([]interface{}, len(ms))
+		for i, m := range ms {
+			dst[i] = m
+		}
+		if err := db.AutoMigrate(dst...); err != nil {
+			errs = append(errs, fmt.Errorf("datasource %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func modelKey(m DbModel) string {
+	if key := m.DbKey(); key != "" {
+		return key
+	}
+	return "*"
+}
@@ -0,0 +1,193 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/trancecho/open-sdk/database"
+	"gorm.io/gorm"
+)
+
+// 消息状态
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed" // 超过最大重试次数，同一聚合后续的消息也会暂停发布，需人工处理后调用 Retry
+)
+
+// Message 发件箱表中的一条待发布消息
+type Message struct {
+	ID            int64  `gorm:"primaryKey"`
+	Aggregate     string `gorm:"size:64;index:idx_outbox_aggregate,priority:1"`
+	AggregateID   string `gorm:"size:64;index:idx_outbox_aggregate,priority:2"`
+	Exchange      string `gorm:"size:255"`
+	RoutingKey    string `gorm:"size:255"`
+	ContentType   string `gorm:"size:64"`
+	Payload       []byte
+	Headers       string    `gorm:"type:text"`
+	Status        string    `gorm:"size:16;index:idx_outbox_status,priority:1"`
+	Attempts      int       `gorm:"not null;default:0"`
+	LastError     string    `gorm:"type:text"`
+	NextAttemptAt time.Time `gorm:"index:idx_outbox_status,priority:2"`
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time `gorm:"index"`
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Event 业务事件。同一 Aggregate+AggregateID 的事件按写入顺序发布，
+// AggregateID 为空的事件之间不保证顺序
+type Event struct {
+	Aggregate   string
+	AggregateID string
+	Exchange    string
+	RoutingKey  string
+	ContentType string            // 默认 application/json
+	Payload     any               // []byte 原样发布，其余类型按 JSON 序列化
+	Headers     map[string]string // 可选
+}
+
+// relays 本进程中按数据源 key 登记的 Relay 唤醒通道，事务提交后唤醒同一数据源的 Relay，减少发布延迟
+var (
+	relayMux sync.Mutex
+	relays   = make(map[string]map[chan struct{}]struct{})
+)
+
+func subscribe(key string, ch chan struct{}) {
+	relayMux.Lock()
+	defer relayMux.Unlock()
+	if relays[key] == nil {
+		relays[key] = make(map[chan struct{}]struct{})
+	}
+	relays[key][ch] = struct{}{}
+}
+
+func unsubscribe(key string, ch chan struct{}) {
+	relayMux.Lock()
+	defer relayMux.Unlock()
+	delete(relays[key], ch)
+	if len(relays[key]) == 0 {
+		delete(relays, key)
+	}
+}
+
+func wake(key string) {
+	relayMux.Lock()
+	defer relayMux.Unlock()
+	for ch := range relays[key] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Add 把事件写入数据源 key 的发件箱表。应在 database.WithTx 的 ctx 中调用，
+// 使事件与业务数据在同一事务中提交或回滚
+func Add(ctx context.Context, key string, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	db := database.Conn(ctx, key)
+	if db == nil {
+		return fmt.Errorf("datasource %s not found", key)
+	}
+	now := time.Now()
+	rows := make([]Message, 0, len(events))
+	for _, e := range events {
+		row, err := e.message(now)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		return err
+	}
+	database.AfterCommit(ctx, func(context.Context) {
+		wake(key)
+	})
+	return nil
+}
+
+func (e Event) message(now time.Time) (Message, error) {
+	msg := Message{
+		Aggregate:     e.Aggregate,
+		AggregateID:   e.AggregateID,
+		Exchange:      e.Exchange,
+		RoutingKey:    e.RoutingKey,
+		ContentType:   e.ContentType,
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if msg.ContentType == "" {
+		msg.ContentType = "application/json"
+	}
+	switch p := e.Payload.(type) {
+	case []byte:
+		msg.Payload = p
+	case json.RawMessage:
+		msg.Payload = p
+	default:
+		payload, err := json.Marshal(p)
+		if err != nil {
+			return msg, fmt.Errorf("marshal outbox payload: %w", err)
+		}
+		msg.Payload = payload
+	}
+	if len(e.Headers) > 0 {
+		headers, err := json.Marshal(e.Headers)
+		if err != nil {
+			return msg, err
+		}
+		msg.Headers = string(headers)
+	}
+	return msg, nil
+}
+
+// Migrate 在数据源 key 上创建发件箱表和 Relay 租约表
+func Migrate(ctx context.Context, key string) error {
+	db := database.Conn(ctx, key)
+	if db == nil {
+		return fmt.Errorf("datasource %s not found", key)
+	}
+	return db.AutoMigrate(&Message{}, &lease{})
+}
+
+// Retry 把失败的消息重新置为待发布，ids 为空时重试全部失败消息，返回受影响的行数
+func Retry(ctx context.Context, key string, ids ...int64) (int64, error) {
+	db := database.Conn(ctx, key)
+	if db == nil {
+		return 0, fmt.Errorf("datasource %s not found", key)
+	}
+	tx := db.Model(&Message{}).Where("status = ?", StatusFailed)
+	if len(ids) > 0 {
+		tx = tx.Where("id IN ?", ids)
+	}
+	result := tx.Updates(map[string]any{
+		"status":          StatusPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	})
+	return result.RowsAffected, result.Error
+}
+
+// pendingScope 可发布的消息：已到重试时间，且同一聚合中没有更早的、尚未到重试时间或已失败的消息
+func pendingScope(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		table := Message{}.TableName()
+		blocked := db.Session(&gorm.Session{NewDB: true}).Table(table+" AS prev").Select("1").
+			Where("prev.aggregate = "+table+".aggregate AND prev.aggregate_id = "+table+".aggregate_id").
+			Where("prev.aggregate_id <> '' AND prev.id < "+table+".id").
+			Where("prev.status = ? OR (prev.status = ? AND prev.next_attempt_at > ?)", StatusFailed, StatusPending, now)
+		return db.Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Where("NOT EXISTS (?)", blocked).
+			Order("id")
+	}
+}
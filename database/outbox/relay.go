@@ -0,0 +1,316 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/trancecho/open-sdk/database"
+	"github.com/trancecho/open-sdk/database/dberr"
+	"github.com/trancecho/open-sdk/mq/publisher"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// errLeaseLost 发布过程中续约失败，租约已被其他实例接管
+var errLeaseLost = errors.New("lease lost")
+
+// lease Relay 的主节点租约，同一数据源上只有持有租约的实例发布消息
+type lease struct {
+	Name      string `gorm:"primaryKey;size:64"`
+	Owner     string `gorm:"size:255"`
+	ExpiresAt time.Time
+}
+
+func (lease) TableName() string {
+	return "outbox_lease"
+}
+
+// Relay 轮询发件箱表并通过 publisher.Publisher 发布消息，保证至少投递一次，
+// 消费者应按 MessageID（outbox-<id>）去重
+type Relay struct {
+	key       string
+	publisher publisher.Publisher
+	owner     string
+
+	batchSize       int
+	pollInterval    time.Duration
+	publishTimeout  time.Duration
+	maxAttempts     int
+	retryBackoff    time.Duration
+	maxBackoff      time.Duration
+	leaseTTL        time.Duration
+	retention       time.Duration
+	cleanupInterval time.Duration
+}
+
+type RelayOption func(*Relay)
+
+// NewRelay 创建数据源 key 上的发件箱 Relay
+func NewRelay(key string, pub publisher.Publisher, opts ...RelayOption) *Relay {
+	host, _ := os.Hostname()
+	r := &Relay{
+		key:             key,
+		publisher:       pub,
+		owner:           fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		batchSize:       100,
+		pollInterval:    time.Second,
+		publishTimeout:  10 * time.Second,
+		maxAttempts:     10,
+		retryBackoff:    time.Second,
+		maxBackoff:      10 * time.Minute,
+		leaseTTL:        30 * time.Second,
+		retention:       7 * 24 * time.Hour,
+		cleanupInterval: time.Hour,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	// 租约至少要覆盖一次发布的最长等待，否则发布途中就可能被其他实例接管
+	r.leaseTTL = max(r.leaseTTL, 2*r.publishTimeout)
+	return r
+}
+
+// WithBatchSize 每轮最多发布的消息数，默认 100
+func WithBatchSize(n int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// WithPollInterval 没有消息时的轮询间隔，默认 1s
+func WithPollInterval(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.pollInterval = d
+	}
+}
+
+// WithPublishTimeout 单条消息等待 broker 确认的最长时间，默认 10s
+func WithPublishTimeout(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.publishTimeout = d
+	}
+}
+
+// WithMaxAttempts 最大发布次数，超过后消息标记为 failed，默认 10
+func WithMaxAttempts(n int) RelayOption {
+	return func(r *Relay) {
+		r.maxAttempts = n
+	}
+}
+
+// WithRetryBackoff 首次重试的等待时间和最大等待时间，之后每次翻倍，默认 1s 和 10m
+func WithRetryBackoff(initial, max time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.retryBackoff, r.maxBackoff = initial, max
+	}
+}
+
+// WithLeaseTTL 主节点租约时长，持有者崩溃后其他实例最多等待该时长接管，默认 30s，
+// 不小于发布超时的 2 倍。发布过程中每过 1/3 租约时长续约一次
+func WithLeaseTTL(d time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.leaseTTL = d
+	}
+}
+
+// WithCleanup 已发布消息的保留时长和清理间隔，默认保留 7 天、每小时清理一次，retention 为 0 时不清理
+func WithCleanup(retention, interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.retention, r.cleanupInterval = retention, interval
+	}
+}
+
+// Run 持续发布消息直到 ctx 取消，多个实例同时运行时只有租约持有者工作
+func (r *Relay) Run(ctx context.Context) error {
+	var lastCleanup time.Time
+	notify := make(chan struct{}, 1)
+	subscribe(r.key, notify)
+	defer unsubscribe(r.key, notify)
+	for {
+		leader, err := r.acquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Println("outbox", r.key, "acquire lease failed:", err)
+		}
+		n := 0
+		if leader {
+			if n, err = r.runBatch(ctx, true); err != nil && ctx.Err() == nil {
+				log.Println("outbox", r.key, "relay failed:", err)
+			}
+			if r.retention > 0 && time.Since(lastCleanup) >= r.cleanupInterval {
+				lastCleanup = time.Now()
+				if _, err = r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+					log.Println("outbox", r.key, "cleanup failed:", err)
+				}
+			}
+		}
+		// 本轮发满一批时立即继续，否则等待轮询间隔或新消息提交
+		if n >= r.batchSize {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-notify:
+		case <-time.After(r.pollInterval):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	r.release()
+	return nil
+}
+
+// RunOnce 发布一批到期的消息，返回本轮处理的消息数。不检查租约，供测试或外部调度使用
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	return r.runBatch(ctx, false)
+}
+
+// runBatch leased 为 true 时在发布过程中按需续约，续约失败立即停止
+func (r *Relay) runBatch(ctx context.Context, leased bool) (int, error) {
+	db := r.conn(ctx)
+	if db == nil {
+		return 0, fmt.Errorf("datasource %s not found", r.key)
+	}
+	renewed := time.Now()
+	var rows []Message
+	if err := db.Scopes(pendingScope(time.Now())).Limit(r.batchSize).Find(&rows).Error; err != nil {
+		return 0, err
+	}
+	// 同一聚合中前面的消息发布失败后，本轮跳过后面的消息以保证顺序
+	blocked := make(map[[2]string]bool)
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		agg := [2]string{row.Aggregate, row.AggregateID}
+		if row.AggregateID != "" && blocked[agg] {
+			continue
+		}
+		if leased && time.Since(renewed) >= r.leaseTTL/3 {
+			leader, err := r.acquire(ctx)
+			if err != nil {
+				return 0, err
+			}
+			if !leader {
+				return 0, errLeaseLost
+			}
+			renewed = time.Now()
+		}
+		if err := r.publish(ctx, row); err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			blocked[agg] = true
+			if err = r.markFailed(db, row, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		now := time.Now()
+		err := db.Model(&Message{ID: row.ID}).Updates(map[string]any{
+			"status":       StatusDelivered,
+			"attempts":     row.Attempts + 1,
+			"last_error":   "",
+			"delivered_at": &now,
+		}).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(rows), nil
+}
+
+func (r *Relay) publish(ctx context.Context, row Message) error {
+	var headers map[string]any
+	if row.Headers != "" {
+		if err := json.Unmarshal([]byte(row.Headers), &headers); err != nil {
+			return fmt.Errorf("decode headers: %w", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.publishTimeout)
+	defer cancel()
+	return r.publisher.Publish(ctx, publisher.Message{
+		Exchange:    row.Exchange,
+		RoutingKey:  row.RoutingKey,
+		ContentType: row.ContentType,
+		MessageID:   fmt.Sprintf("outbox-%d", row.ID),
+		Headers:     headers,
+		Body:        row.Payload,
+	})
+}
+
+// markFailed 记录失败并按指数退避安排下次重试，超过最大次数时标记为 failed
+func (r *Relay) markFailed(db *gorm.DB, row Message, cause error) error {
+	attempts := row.Attempts + 1
+	updates := map[string]any{"attempts": attempts, "last_error": cause.Error()}
+	if r.maxAttempts > 0 && attempts >= r.maxAttempts {
+		updates["status"] = StatusFailed
+		log.Println("outbox", r.key, "message", row.ID, "failed after", attempts, "attempts:", cause)
+	} else {
+		backoff := r.retryBackoff
+		for i := 1; i < attempts && backoff < r.maxBackoff; i++ {
+			backoff *= 2
+		}
+		updates["next_attempt_at"] = time.Now().Add(min(backoff, r.maxBackoff))
+	}
+	return db.Model(&Message{ID: row.ID}).Updates(updates).Error
+}
+
+// Cleanup 删除超过保留时长的已发布消息，返回删除的行数
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	db := r.conn(ctx)
+	if db == nil {
+		return 0, fmt.Errorf("datasource %s not found", r.key)
+	}
+	result := db.Where("status = ? AND delivered_at < ?", StatusDelivered, time.Now().Add(-r.retention)).
+		Delete(&Message{})
+	return result.RowsAffected, result.Error
+}
+
+// acquire 续约或抢占过期的租约，返回当前实例是否为主节点
+func (r *Relay) acquire(ctx context.Context) (bool, error) {
+	db := r.conn(ctx)
+	if db == nil {
+		return false, fmt.Errorf("datasource %s not found", r.key)
+	}
+	now := time.Now()
+	result := db.Model(&lease{}).
+		Where("name = ? AND (owner = ? OR expires_at < ?)", r.key, r.owner, now).
+		Updates(map[string]any{"owner": r.owner, "expires_at": now.Add(r.leaseTTL)})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error == nil, result.Error
+	}
+	err := db.Create(&lease{Name: r.key, Owner: r.owner, ExpiresAt: now.Add(r.leaseTTL)}).Error
+	if err != nil {
+		if dberr.IsDuplicate(err) || errors.Is(err, gorm.ErrDuplicatedKey) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// release 退出时释放租约，使其他实例立即接管
+func (r *Relay) release() {
+	// 使用独立 context，确保 Run 的 ctx 取消后仍能释放
+	if db := r.conn(context.Background()); db != nil {
+		db.Where("name = ? AND owner = ?", r.key, r.owner).Delete(&lease{})
+	}
+}
+
+// conn 返回固定走主库的连接，租约和待发布消息不能从有延迟的副本读取
+func (r *Relay) conn(ctx context.Context) *gorm.DB {
+	db := database.Conn(ctx, r.key)
+	if db == nil {
+		return nil
+	}
+	return db.Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/trancecho/open-sdk/config"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Primary 强制走主库的 scope，用于写后立即读等场景
+//
+//	database.GetDb("MainMysql").Scopes(database.Primary).First(&user)
+func Primary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write)
+}
+
+// useReplicas 为数据源挂载只读副本：普通查询按策略分发到副本，写入、事务和 Primary scope 走主库
+func useReplicas(db *gorm.DB, creator Creator, source config.Datasource) error {
+	dialectors := make([]gorm.Dialector, 0, len(source.Replicas))
+	for _, replica := range source.Replicas {
+		dialector, err := creator.Dialector(replicaDatasource(source, replica))
+		if err != nil {
+			return err
+		}
+		dialectors = append(dialectors, dialector)
+	}
+	policy, err := newPolicy(source.Policy)
+	if err != nil {
+		return err
+	}
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   policy,
+	})
+	if source.MaxOpenConns > 0 {
+		resolver.SetMaxOpenConns(source.MaxOpenConns)
+	}
+	if source.MaxIdleConns > 0 {
+		resolver.SetMaxIdleConns(source.MaxIdleConns)
+	}
+	if source.ConnMaxLifetime > 0 {
+		resolver.SetConnMaxLifetime(source.ConnMaxLifetime)
+	}
+	if source.ConnMaxIdleTime > 0 {
+		resolver.SetConnMaxIdleTime(source.ConnMaxIdleTime)
+	}
+	return db.Use(resolver)
+}
+
+// replicaPools 返回数据源挂载的只读副本连接池，未配置副本时为空
+func replicaPools(db *gorm.DB) []gorm.ConnPool {
+	resolver, ok := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver)
+	if !ok {
+		return nil
+	}
+	primary, _ := db.DB()
+	var pools []gorm.ConnPool
+	_ = resolver.Call(func(pool gorm.ConnPool) error {
+		if sqlDB, ok := pool.(*sql.DB); !ok || sqlDB != primary {
+			pools = append(pools, pool)
+		}
+		return nil
+	})
+	return pools
+}
+
+// replicaDatasource 副本未填写的字段沿用主库配置
+func replicaDatasource(source config.Datasource, replica config.Replica) config.Datasource {
+	conf := source
+	conf.IP = replica.IP
+	if replica.PORT != "" {
+		conf.PORT = replica.PORT
+	}
+	if replica.USER != "" {
+		conf.USER = replica.USER
+	}
+	if replica.PASSWORD != "" {
+		conf.PASSWORD = replica.PASSWORD
+	}
+	if replica.DATABASE != "" {
+		conf.DATABASE = replica.DATABASE
+	}
+	conf.Replicas = nil
+	return conf
+}
+
+func newPolicy(name string) (dbresolver.Policy, error) {
+	switch name {
+	case "", "random":
+		return dbresolver.RandomPolicy{}, nil
+	case "round-robin":
+		return dbresolver.StrictRoundRobinPolicy(), nil
+	case "least-latency":
+		return &latencyPolicy{interval: 10 * time.Second}, nil
+	}
+	return nil, fmt.Errorf("unknown replica policy %q", name)
+}
+
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// latencyPolicy 选择最近一次探测延迟最低的副本
+// 探测在 Resolve 中按 interval 异步触发，不常驻后台协程
+type latencyPolicy struct {
+	interval  time.Duration
+	latencies sync.Map // gorm.ConnPool => time.Duration
+	lastProbe atomic.Int64
+	probing   atomic.Bool
+}
+
+func (p *latencyPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	now := time.Now().UnixNano()
+	if now-p.lastProbe.Load() >= int64(p.interval) && p.probing.CompareAndSwap(false, true) {
+		p.lastProbe.Store(now)
+		go p.probe(connPools)
+	}
+	best, bestLatency := connPools[0], time.Duration(math.MaxInt64)
+	for _, pool := range connPools {
+		latency := time.Duration(0) // 未探测过的副本优先，以便尽快得到延迟数据
+		if v, ok := p.latencies.Load(pool); ok {
+			latency = v.(time.Duration)
+		}
+		if latency < bestLatency {
+			best, bestLatency = pool, latency
+		}
+	}
+	return best
+}
+
+func (p *latencyPolicy) probe(connPools []gorm.ConnPool) {
+	defer p.probing.Store(false)
+	for _, pool := range connPools {
+		pg, ok := pool.(pinger)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+		start := time.Now()
+		latency := time.Duration(math.MaxInt64) // 探测失败的副本排到最后
+		if err := pg.PingContext(ctx); err == nil {
+			latency = time.Since(start)
+		}
+		cancel()
+		p.latencies.Store(pool, latency)
+	}
+}
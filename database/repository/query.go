@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/clause"
+)
+
+// 过滤操作符
+const (
+	OpEq     = "eq"
+	OpNe     = "ne"
+	OpGt     = "gt"
+	OpGte    = "gte"
+	OpLt     = "lt"
+	OpLte    = "lte"
+	OpLike   = "like"
+	OpIn     = "in"
+	OpIsNull = "null" // Value 为 false 时表示 IS NOT NULL
+)
+
+// Query 列表查询条件
+type Query struct {
+	Filters  []Filter
+	Sort     []string // 列名，前缀 - 表示倒序，如 "-created_at"
+	Page     int
+	Limit    int
+	Unscoped bool // 包含已软删除的记录
+}
+
+// Filter 单个过滤条件，多个条件之间为 AND
+type Filter struct {
+	Column string
+	Op     string
+	Value  any
+}
+
+// Where 构造过滤条件的简写
+func Where(column, op string, value any) Filter {
+	return Filter{Column: column, Op: op, Value: value}
+}
+
+func (f Filter) expression(column string) (clause.Expression, error) {
+	col := clause.Column{Table: clause.CurrentTable, Name: column}
+	switch f.Op {
+	case OpEq, "":
+		return clause.Eq{Column: col, Value: f.Value}, nil
+	case OpNe:
+		return clause.Neq{Column: col, Value: f.Value}, nil
+	case OpGt:
+		return clause.Gt{Column: col, Value: f.Value}, nil
+	case OpGte:
+		return clause.Gte{Column: col, Value: f.Value}, nil
+	case OpLt:
+		return clause.Lt{Column: col, Value: f.Value}, nil
+	case OpLte:
+		return clause.Lte{Column: col, Value: f.Value}, nil
+	case OpLike:
+		return clause.Like{Column: col, Value: f.Value}, nil
+	case OpIn:
+		rv := reflect.ValueOf(f.Value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, fmt.Errorf("filter %s in: value must be a slice", f.Column)
+		}
+		values := make([]any, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+		}
+		return clause.IN{Column: col, Values: values}, nil
+	case OpIsNull:
+		if isNull, _ := f.Value.(bool); !isNull {
+			return clause.Neq{Column: col, Value: nil}, nil
+		}
+		return clause.Eq{Column: col, Value: nil}, nil
+	}
+	return nil, fmt.Errorf("unknown filter op %q", f.Op)
+}
+
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case uint:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
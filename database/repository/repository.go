@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/trancecho/open-sdk/database"
+	"github.com/trancecho/open-sdk/pkg/utils/page"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	// ErrVersionConflict 乐观锁冲突：记录已被其他请求修改或已删除
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrInvalidColumn 过滤或排序使用了模型中不存在的列
+	ErrInvalidColumn = errors.New("invalid column")
+	// ErrMissingPrimaryKey 按主键更新时 entity 的主键为零值
+	ErrMissingPrimaryKey = errors.New("missing primary key")
+)
+
+const versionField = "Version"
+
+// Repository 基于 gorm 的通用 CRUD 仓储，T 为模型结构体（通常嵌入 model.BaseModel）
+// 所有方法都从 ctx 中查找所属数据源的事务（见 database.NewTxContext），找到时加入该事务
+type Repository[T any] struct {
+	key string
+	tx  *gorm.DB // WithTx 绑定的事务，优先于 ctx
+
+	once   sync.Once
+	schema *schema.Schema
+	err    error
+}
+
+// New 创建数据源 key 上的仓储，连接在每次调用时获取，因此可以在 InitDB 之前创建
+func New[T any](key string) *Repository[T] {
+	return &Repository[T]{key: key}
+}
+
+// NewFor 创建模型 DbKey 对应数据源上的仓储
+func NewFor[T database.DbModel]() *Repository[T] {
+	var m T
+	return New[T](m.DbKey())
+}
+
+// WithTx 返回绑定到显式事务的仓储副本
+func (r *Repository[T]) WithTx(tx *gorm.DB) *Repository[T] {
+	return &Repository[T]{key: r.key, tx: tx}
+}
+
+// DB 返回本次调用应使用的连接：显式事务 > ctx 中的事务 > 数据源连接
+func (r *Repository[T]) DB(ctx context.Context) *gorm.DB {
+	if r.tx != nil {
+		return r.tx.WithContext(ctx)
+	}
+	return database.Conn(ctx, r.key)
+}
+
+func (r *Repository[T]) conn(ctx context.Context) (*gorm.DB, error) {
+	db := r.DB(ctx)
+	if db == nil {
+		return nil, fmt.Errorf("datasource %s not found", r.key)
+	}
+	return db, nil
+}
+
+func (r *Repository[T]) parse(db *gorm.DB) (*schema.Schema, error) {
+	r.once.Do(func() {
+		stmt := &gorm.Statement{DB: db}
+		r.err = stmt.Parse(new(T))
+		r.schema = stmt.Schema
+	})
+	return r.schema, r.err
+}
+
+// Create 插入一条记录
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Create(entity).Error
+}
+
+// byID 以主键列构造条件。不使用 First(entity, id) 的内联条件：
+// gorm 会把非数字的字符串参数当作原始 SQL，字符串主键查不到且可被注入
+func (r *Repository[T]) byID(db *gorm.DB, id any) (*gorm.DB, error) {
+	s, err := r.parse(db)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.PrimaryFields) != 1 {
+		return nil, fmt.Errorf("%s: %w: expected exactly one primary key", s.Name, ErrMissingPrimaryKey)
+	}
+	col := clause.Column{Table: clause.CurrentTable, Name: s.PrimaryFields[0].DBName}
+	if rv := reflect.ValueOf(id); (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]any, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+		}
+		return db.Where(clause.IN{Column: col, Values: values}), nil
+	}
+	return db.Where(clause.Eq{Column: col, Value: id}), nil
+}
+
+// GetByID 按主键查询，不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) GetByID(ctx context.Context, id any) (*T, error) {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := r.byID(db, id)
+	if err != nil {
+		return nil, err
+	}
+	entity := new(T)
+	if err = tx.First(entity).Error; err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// Update 按主键部分更新 entity 中的字段，fields 为空时只更新非零值字段
+// 模型带 Version 字段时启用乐观锁：以 entity 的当前版本为条件更新并自增版本，
+// 没有行被更新时返回 ErrVersionConflict，成功后 entity 的版本同步加一；主键为零值时返回 ErrMissingPrimaryKey
+func (r *Repository[T]) Update(ctx context.Context, entity *T, fields ...string) error {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := r.parse(db)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(entity)
+	if len(s.PrimaryFields) == 0 {
+		return ErrMissingPrimaryKey
+	}
+	for _, pk := range s.PrimaryFields {
+		// 乐观锁的版本条件会绕过 gorm 的缺少 WHERE 检查，主键为零值时会更新整张表
+		if _, zero := pk.ValueOf(ctx, rv.Elem()); zero {
+			return ErrMissingPrimaryKey
+		}
+	}
+	tx := db.Model(entity)
+	version := s.LookUpField(versionField)
+	if version == nil {
+		if len(fields) > 0 {
+			tx = tx.Select(fields)
+		}
+		return tx.Updates(entity).Error
+	}
+
+	current, _ := version.ValueOf(ctx, rv)
+	tx = tx.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: version.DBName}, Value: current})
+	if len(fields) > 0 {
+		tx = tx.Select(append(append([]string(nil), fields...), version.Name))
+	}
+	next := toInt64(current) + 1
+	if err = version.Set(ctx, rv, next); err != nil {
+		return err
+	}
+	result := tx.Updates(entity)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrVersionConflict
+	}
+	if result.Error != nil {
+		_ = version.Set(ctx, rv, current)
+	}
+	return result.Error
+}
+
+// Delete 按主键删除，id 为切片时删除多条，模型带 gorm.DeletedAt 时为软删除
+func (r *Repository[T]) Delete(ctx context.Context, id any) error {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := r.byID(db, id)
+	if err != nil {
+		return err
+	}
+	return tx.Delete(new(T)).Error
+}
+
+// HardDelete 按主键物理删除，忽略软删除
+func (r *Repository[T]) HardDelete(ctx context.Context, id any) error {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := r.byID(db, id)
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(new(T)).Error
+}
+
+// List 按条件查询，Page/Limit 为 0 时使用 page.Paginate 的默认值
+func (r *Repository[T]) List(ctx context.Context, q Query) ([]T, error) {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := r.apply(db.Model(new(T)), q, true)
+	if err != nil {
+		return nil, err
+	}
+	var list []T
+	err = tx.Scopes(page.Paginate(q.Page, q.Limit)).Find(&list).Error
+	return list, err
+}
+
+// Count 统计满足过滤条件的记录数，忽略排序和分页
+func (r *Repository[T]) Count(ctx context.Context, q Query) (int64, error) {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := r.apply(db.Model(new(T)), q, false)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	err = tx.Count(&total).Error
+	return total, err
+}
+
+// apply 把过滤和排序条件应用到查询上，列名必须是模型中存在的字段
+func (r *Repository[T]) apply(tx *gorm.DB, q Query, withSort bool) (*gorm.DB, error) {
+	s, err := r.parse(tx)
+	if err != nil {
+		return nil, err
+	}
+	if q.Unscoped {
+		tx = tx.Unscoped()
+	}
+	for _, f := range q.Filters {
+		field := lookupColumn(s, f.Column)
+		if field == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidColumn, f.Column)
+		}
+		expr, err := f.expression(field.DBName)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(expr)
+	}
+	if !withSort {
+		return tx, nil
+	}
+	for _, sort := range q.Sort {
+		desc := strings.HasPrefix(sort, "-")
+		field := lookupColumn(s, strings.TrimPrefix(sort, "-"))
+		if field == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidColumn, sort)
+		}
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Desc: desc})
+	}
+	return tx, nil
+}
+
+// lookupColumn 支持数据库列名和结构体字段名
+func lookupColumn(s *schema.Schema, name string) *schema.Field {
+	if field := s.LookUpField(name); field != nil && field.DBName != "" {
+		return field
+	}
+	return nil
+}
+
+// Page 偏移分页查询，同时返回总数
+func (r *Repository[T]) Page(ctx context.Context, q Query) (page.PageResult[T], error) {
+	total, err := r.Count(ctx, q)
+	if err != nil {
+		return page.PageResult[T]{}, err
+	}
+	list, err := r.List(ctx, q)
+	if err != nil {
+		return page.PageResult[T]{}, err
+	}
+	return page.NewPageResult(list, total, q.Page, q.Limit), nil
+}
+
+// CursorQuery 游标分页查询条件
+type CursorQuery struct {
+	Filters   []Filter
+	Sort      []string // 同 Query.Sort，未包含主键时自动追加主键升序作为唯一排序
+	Cursor    string   // 上一页返回的 NextCursor，为空表示第一页
+	Limit     int
+	WithTotal bool // 是否统计总数，大表上代价较高
+}
+
+// ListCursor 游标（keyset）分页查询，适合大表和无限滚动
+func (r *Repository[T]) ListCursor(ctx context.Context, q CursorQuery) (page.PageResult[T], error) {
+	var result page.PageResult[T]
+	db, err := r.conn(ctx)
+	if err != nil {
+		return result, err
+	}
+	s, err := r.parse(db)
+	if err != nil {
+		return result, err
+	}
+	columns := make([]page.SortColumn, 0, len(q.Sort)+1)
+	hasPrimary := false
+	for _, c := range page.ParseSort(q.Sort...) {
+		field := lookupColumn(s, c.Column)
+		if field == nil {
+			return result, fmt.Errorf("%w: %s", ErrInvalidColumn, c.Column)
+		}
+		hasPrimary = hasPrimary || field == s.PrioritizedPrimaryField
+		columns = append(columns, page.SortColumn{Column: field.DBName, Desc: c.Desc})
+	}
+	if !hasPrimary && s.PrioritizedPrimaryField != nil {
+		columns = append(columns, page.SortColumn{Column: s.PrioritizedPrimaryField.DBName})
+	}
+	keyset, err := page.Keyset(columns, q.Cursor, q.Limit)
+	if err != nil {
+		return result, err
+	}
+
+	var total int64
+	if q.WithTotal {
+		if total, err = r.Count(ctx, Query{Filters: q.Filters}); err != nil {
+			return result, err
+		}
+	}
+	tx, err := r.apply(db.Model(new(T)), Query{Filters: q.Filters}, false)
+	if err != nil {
+		return result, err
+	}
+	var list []T
+	if err = tx.Scopes(keyset).Find(&list).Error; err != nil {
+		return result, err
+	}
+	return page.CursorResult(db, list, columns, q.Limit, total)
+}
@@ -0,0 +1,59 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/trancecho/open-sdk/database/repository"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type tag struct {
+	Name  string `gorm:"primaryKey"`
+	Count int
+}
+
+func TestStringPrimaryKey(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&tag{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	repo := repository.New[tag]("test").WithTx(db)
+	for _, name := range []string{"go", "rust", "1"} {
+		if err = repo.Create(ctx, &tag{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := repo.GetByID(ctx, "go")
+	if err != nil || got.Name != "go" {
+		t.Fatalf("get by string id: %+v %v", got, err)
+	}
+	if _, err = repo.GetByID(ctx, "1 OR 1=1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	// 字符串参数必须作为值绑定，不能拼成原始 SQL
+	if err = repo.Delete(ctx, "1 OR 1=1"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := repo.Count(ctx, repository.Query{}); n != 3 {
+		t.Fatalf("injected id must not delete rows, %d left", n)
+	}
+	if err = repo.Delete(ctx, []string{"go", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.HardDelete(ctx, "rust"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := repo.Count(ctx, repository.Query{}); n != 0 {
+		t.Fatalf("expected all rows deleted, %d left", n)
+	}
+}
@@ -0,0 +1,112 @@
+package sqllog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/trancecho/open-sdk/database/dberr"
+	"github.com/trancecho/open-sdk/logx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var levels = map[string]logger.LogLevel{
+	"":       logger.Silent,
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
+// ParseLevel 解析配置中的日志级别：silent、error、warn、info，空字符串为 silent
+func ParseLevel(level string) (logger.LogLevel, error) {
+	l, ok := levels[strings.ToLower(level)]
+	if !ok {
+		return logger.Silent, fmt.Errorf("unknown gorm log level %q", level)
+	}
+	return l, nil
+}
+
+// Logger 把 gorm 的 SQL 日志写入 logx，携带数据源、耗时、影响行数、错误分类以及 context 中的请求 ID 和用户 ID
+//   - error 级别：记录执行出错的 SQL（忽略记录未找到）
+//   - warn 级别：另外记录超过慢 SQL 阈值的 SQL
+//   - info 级别：记录所有 SQL
+type Logger struct {
+	Datasource    string
+	Level         logger.LogLevel
+	SlowThreshold time.Duration
+	// RedactParams 为 true 时日志中的 SQL 保留占位符，不展开绑定参数
+	RedactParams bool
+}
+
+// New 创建 logx 日志适配器，slowThreshold 为 0 时使用 1s
+func New(datasource string, level logger.LogLevel, slowThreshold time.Duration, redactParams bool) *Logger {
+	if slowThreshold <= 0 {
+		slowThreshold = time.Second
+	}
+	return &Logger{Datasource: datasource, Level: level, SlowThreshold: slowThreshold, RedactParams: redactParams}
+}
+
+func (l *Logger) LogMode(level logger.LogLevel) logger.Interface {
+	next := *l
+	next.Level = level
+	return &next
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.Level >= logger.Info {
+		logx.Info(fmt.Sprintf(msg, data...), l.fields(ctx)...)
+	}
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.Level >= logger.Warn {
+		logx.Warn(fmt.Sprintf(msg, data...), l.fields(ctx)...)
+	}
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.Level >= logger.Error {
+		logx.Error(fmt.Sprintf(msg, data...), l.fields(ctx)...)
+	}
+}
+
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.Level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	record := func(log func(string, ...zap.Field), msg string, extra ...zap.Field) {
+		sql, rows := fc()
+		fields := append(l.fields(ctx),
+			zap.String("sql", sql),
+			zap.Int64("rows", rows),
+			zap.Duration("elapsed", elapsed),
+		)
+		log(msg, append(fields, extra...)...)
+	}
+	switch {
+	case err != nil && l.Level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		record(logx.Error, "sql error", zap.Error(err), zap.String("error_class", dberr.Classify(err)))
+	case elapsed > l.SlowThreshold && l.Level >= logger.Warn:
+		record(logx.Warn, "slow sql", zap.Duration("slow_threshold", l.SlowThreshold))
+	case l.Level >= logger.Info:
+		record(logx.Info, "sql")
+	}
+}
+
+// ParamsFilter 实现 gorm.ParamsFilter，开启 RedactParams 时不把参数展开进日志
+func (l *Logger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.RedactParams {
+		return sql, nil
+	}
+	return sql, params
+}
+
+func (l *Logger) fields(ctx context.Context) []zap.Field {
+	return append([]zap.Field{zap.String("datasource", l.Datasource)}, logx.ContextFields(ctx)...)
+}
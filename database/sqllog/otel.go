@@ -0,0 +1,86 @@
+package sqllog
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+
+	"github.com/trancecho/open-sdk/database/dberr"
+)
+
+const (
+	tracerName  = "github.com/trancecho/open-sdk/database"
+	spanKey     = "sqllog:span"
+	callbackKey = "sqllog:otel"
+)
+
+// TracingPlugin 为每条 SQL 创建 OpenTelemetry span，使用全局 TracerProvider
+// span 中记录参数占位形式的 SQL，不包含绑定参数
+type TracingPlugin struct {
+	Datasource string
+	System     string // db.system，如 mysql、postgresql
+}
+
+func (p TracingPlugin) Name() string {
+	return callbackKey
+}
+
+func (p TracingPlugin) Initialize(db *gorm.DB) error {
+	tracer := otel.Tracer(tracerName)
+	before := func(op string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if tx.Statement.Context == nil {
+				return
+			}
+			ctx, span := tracer.Start(tx.Statement.Context, "gorm."+op, trace.WithSpanKind(trace.SpanKindClient))
+			tx.Statement.Context = ctx
+			tx.InstanceSet(spanKey, span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		defer span.End()
+		span.SetAttributes(
+			attribute.String("db.system", p.System),
+			attribute.String("db.name", p.Datasource),
+			attribute.String("db.statement", tx.Statement.SQL.String()),
+			attribute.String("db.sql.table", tx.Statement.Table),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+		if err := tx.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.SetAttributes(attribute.String("db.error_class", dberr.Classify(err)))
+		}
+	}
+
+	cb := db.Callback()
+	for _, reg := range []struct {
+		op     string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	} {
+		if err := reg.before(callbackKey+":before_"+reg.op, before(reg.op)); err != nil {
+			return err
+		}
+		if err := reg.after(callbackKey+":after_"+reg.op, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	callbackKey = "tenant"
+	skipKey     = "tenant:skip"
+
+	defaultColumn = "tenant_id"
+)
+
+var (
+	// ErrNoTenant 操作租户隔离的模型时 context 中没有租户
+	ErrNoTenant = errors.New("tenant not found in context")
+	// ErrTenantMismatch 写入的记录属于其他租户
+	ErrTenantMismatch = errors.New("tenant mismatch")
+)
+
+// TenantScoped 按租户隔离的模型，通常通过嵌入 model.TenantModel 实现
+type TenantScoped interface {
+	TenantScoped()
+}
+
+type tenantKey struct{}
+type skipContextKey struct{}
+
+// WithTenant 把租户 ID 放入 context
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// FromContext 从 context 取 WithTenant 设置的租户 ID
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if v, ok := ctx.Value(tenantKey{}).(string); ok && v != "" {
+		return v, true
+	}
+	return "", false
+}
+
+// WithoutTenant 返回跨租户的 context，使用它的查询和写入不做租户隔离，仅用于管理后台等场景
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipContextKey{}, true)
+}
+
+// AllTenants 跨租户查询的 scope，效果同 WithoutTenant
+//
+//	db.Scopes(tenant.AllTenants).Find(&orders)
+func AllTenants(db *gorm.DB) *gorm.DB {
+	return db.Set(skipKey, true)
+}
+
+// Plugin gorm 租户隔离插件：对实现 TenantScoped 的模型，
+// 查询、更新、删除自动追加 tenant_id = ? 条件，新增和更新时自动填充租户列，
+// 写入其他租户的租户列时返回 ErrTenantMismatch。
+// Raw/Exec 的原生 SQL 和 Joins 关联的表不做处理
+type Plugin struct {
+	column string
+}
+
+type Option func(*Plugin)
+
+// New 创建租户隔离插件，通过 db.Use 启用，也可在数据源配置中设置 Tenant: true
+func New(opts ...Option) *Plugin {
+	p := &Plugin{column: defaultColumn}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WithColumn 自定义租户列名，默认 tenant_id
+func WithColumn(column string) Option {
+	return func(p *Plugin) {
+		p.column = column
+	}
+}
+
+func (p *Plugin) Name() string {
+	return "open-sdk:tenant"
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	// 排在最前，保证其他插件（如 audit）看到的条件已包含租户
+	for _, reg := range []struct {
+		name string
+		fn   func(string, func(*gorm.DB)) error
+		cb   func(*gorm.DB)
+	}{
+		{"create", cb.Create().Before("*").Register, p.create},
+		{"query", cb.Query().Before("*").Register, p.where},
+		{"update", cb.Update().Before("*").Register, p.update},
+		{"delete", cb.Delete().Before("*").Register, p.where},
+		{"row", cb.Row().Before("*").Register, p.where},
+	} {
+		if err := reg.fn(callbackKey+":"+reg.name, reg.cb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tenant 返回当前语句需要使用的租户，不需要隔离时 scoped 为 false
+func (p *Plugin) tenant(db *gorm.DB) (tenantID string, scoped bool) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Schema.LookUpField(p.column) == nil {
+		return "", false
+	}
+	if _, ok := reflect.New(stmt.Schema.ModelType).Interface().(TenantScoped); !ok {
+		return "", false
+	}
+	if skip, ok := db.Get(skipKey); ok && skip == true {
+		return "", false
+	}
+	if stmt.Context != nil {
+		if skip, _ := stmt.Context.Value(skipContextKey{}).(bool); skip {
+			return "", false
+		}
+	}
+	tenantID, ok := FromContext(stmt.Context)
+	if !ok {
+		_ = db.AddError(fmt.Errorf("%w: %s", ErrNoTenant, stmt.Table))
+		return "", false
+	}
+	return tenantID, true
+}
+
+func (p *Plugin) where(db *gorm.DB) {
+	tenantID, scoped := p.tenant(db)
+	if !scoped {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: p.column}, Value: tenantID},
+	}})
+}
+
+// create 填充租户列，记录已指定其他租户时拒绝写入
+func (p *Plugin) create(db *gorm.DB) {
+	tenantID, scoped := p.tenant(db)
+	if !scoped {
+		return
+	}
+	p.fill(db, tenantID, db.Statement.ReflectValue)
+}
+
+// update 追加租户条件，并禁止 SET 把记录改到其他租户（WHERE 按原租户过滤，SET 会写入新值）
+func (p *Plugin) update(db *gorm.DB) {
+	tenantID, scoped := p.tenant(db)
+	if !scoped {
+		return
+	}
+	p.where(db)
+	stmt := db.Statement
+	field := stmt.Schema.LookUpField(p.column)
+	switch dest := stmt.Dest.(type) {
+	case map[string]any:
+		for _, key := range []string{field.DBName, field.Name} {
+			if v, ok := dest[key]; ok && fmt.Sprint(v) != tenantID {
+				_ = db.AddError(fmt.Errorf("%w: %s set to tenant %v", ErrTenantMismatch, stmt.Table, v))
+				return
+			}
+		}
+	default:
+		if rv := reflect.Indirect(reflect.ValueOf(dest)); rv.Kind() == reflect.Struct && rv.Type() == stmt.Schema.ModelType {
+			p.fill(db, tenantID, rv)
+		} else {
+			p.fill(db, tenantID, stmt.ReflectValue)
+		}
+	}
+}
+
+// fill 把记录中为空的租户列设为当前租户，已是其他租户时报错
+func (p *Plugin) fill(db *gorm.DB, tenantID string, value reflect.Value) {
+	stmt := db.Statement
+	field := stmt.Schema.LookUpField(p.column)
+	set := func(rv reflect.Value) {
+		v, zero := field.ValueOf(stmt.Context, rv)
+		if zero {
+			if !rv.CanAddr() {
+				return
+			}
+			if err := field.Set(stmt.Context, rv, tenantID); err != nil {
+				_ = db.AddError(err)
+			}
+			return
+		}
+		if fmt.Sprint(v) != tenantID {
+			_ = db.AddError(fmt.Errorf("%w: %s record of tenant %v", ErrTenantMismatch, stmt.Table, v))
+		}
+	}
+	switch rv := value; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if elem := reflect.Indirect(rv.Index(i)); elem.Kind() == reflect.Struct {
+				set(elem)
+			}
+		}
+	case reflect.Struct:
+		set(rv)
+	case reflect.Map:
+		stmt.SetColumn(p.column, tenantID, true)
+	}
+}
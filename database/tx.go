@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/trancecho/open-sdk/database/dberr"
+	"gorm.io/gorm"
+	"log"
+	"math/rand"
+	"time"
+)
+
+type txOptions struct {
+	maxRetries int
+	backoff    time.Duration
+	sqlOptions *sql.TxOptions
+}
+
+type TxOption func(*txOptions)
+
+// WithRetries 死锁或序列化冲突时整个事务的最大重试次数，默认 3
+func WithRetries(n int) TxOption {
+	return func(o *txOptions) {
+		o.maxRetries = n
+	}
+}
+
+// WithRetryBackoff 首次重试前的等待时间，之后每次翻倍并加随机抖动，默认 20ms
+func WithRetryBackoff(d time.Duration) TxOption {
+	return func(o *txOptions) {
+		o.backoff = d
+	}
+}
+
+// WithIsolation 设置事务隔离级别，嵌套调用时忽略
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		o.sqlOptions = &sql.TxOptions{Isolation: level}
+	}
+}
+
+// WithTx 在数据源 key 上开启事务执行 fn，事务保存在传给 fn 的 ctx 中，
+// 使用该 ctx 的仓储调用和 Conn 会自动加入事务。
+// ctx 中已有该数据源的事务时，以保存点的方式嵌套，fn 失败只回滚到保存点；
+// 最外层事务遇到死锁或序列化冲突时会整体重试，提交成功后按注册顺序执行 AfterCommit 回调
+func WithTx(ctx context.Context, key string, fn func(ctx context.Context) error, opts ...TxOption) error {
+	if key == "" {
+		key = "*"
+	}
+	if parent := txStateOf(ctx, key); parent != nil {
+		return nested(ctx, parent, fn)
+	}
+	db := GetDb(key)
+	if db == nil {
+		return fmt.Errorf("datasource %s not found", key)
+	}
+	o := &txOptions{maxRetries: 3, backoff: 20 * time.Millisecond}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	backoff := o.backoff
+	for attempt := 0; ; attempt++ {
+		var state *txState
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var txCtx context.Context
+			txCtx, state = withTxState(ctx, key, tx)
+			return fn(txCtx)
+		}, o.sqlOptions)
+		if err == nil {
+			runHooks(ctx, state.hooks)
+			return nil
+		}
+		if attempt >= o.maxRetries || !dberr.IsRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+		log.Println("transaction on datasource", key, "conflicted, retry in", backoff, ":", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff + time.Duration(rand.Int63n(int64(backoff)+1))):
+		}
+		backoff *= 2
+	}
+}
+
+// nested 在父事务中创建保存点，成功后把回调并入父事务，失败时丢弃
+func nested(ctx context.Context, parent *txState, fn func(ctx context.Context) error) error {
+	var state *txState
+	err := parent.tx.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var txCtx context.Context
+		txCtx, state = withTxState(ctx, parent.key, tx)
+		return fn(txCtx)
+	})
+	if err == nil {
+		parent.hooks = append(parent.hooks, state.hooks...)
+	}
+	return err
+}
+
+// AfterCommit 注册在 ctx 中当前事务提交后执行的回调，如提交后再发布 MQ 消息；
+// 事务回滚（包括嵌套保存点回滚）时回调被丢弃，ctx 不在事务中时立即执行
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if txc, ok := ctx.Value(txContextKey{}).(*txContext); ok && txc.current != nil {
+		txc.current.hooks = append(txc.current.hooks, fn)
+		return
+	}
+	fn(ctx)
+}
+
+func runHooks(ctx context.Context, hooks []func(ctx context.Context)) {
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Println("after commit hook panic:", r)
+				}
+			}()
+			hook(ctx)
+		}()
+	}
+}
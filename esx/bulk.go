@@ -0,0 +1,423 @@
+package esx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// 批量操作类型
+const (
+	BulkIndex  = "index"
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkItem 一条批量操作
+type BulkItem struct {
+	Action  string // 默认 index
+	Index   string // 为空时使用 WithBulkIndex 设置的默认索引
+	ID      string
+	Doc     any   // update 时为部分文档，delete 时忽略；序列化规则同 jsonReader
+	Upsert  bool  // update 时文档不存在则以 Doc 创建
+	Version int64 // 大于 0 时使用外部版本号，旧版本的写入会被集群拒绝（不适用于 update）
+
+	OnSuccess func(ctx context.Context, item BulkItem, res BulkItemResponse)
+	OnFailure func(ctx context.Context, item BulkItem, res BulkItemResponse, err error)
+}
+
+// BulkItemResponse 单条操作的结果
+type BulkItemResponse struct {
+	Index  string `json:"_index"`
+	ID     string `json:"_id"`
+	Result string `json:"result"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
+
+// BulkStats 批量写入统计
+type BulkStats struct {
+	Added     uint64 // 调用 Add 的条数
+	Succeeded uint64
+	Failed    uint64 // 最终失败的条数
+	Retried   uint64 // 重试的条数（同一条多次重试分别计数）
+	Requests  uint64 // 发送的 _bulk 请求数
+}
+
+type bulkOptions struct {
+	index         string
+	workers       int
+	flushBytes    int
+	flushItems    int
+	flushInterval time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+	refresh       string
+	onError       func(ctx context.Context, err error)
+}
+
+type BulkOption func(*bulkOptions)
+
+// WithBulkIndex 条目未指定索引时使用的默认索引
+func WithBulkIndex(index string) BulkOption {
+	return func(o *bulkOptions) {
+		o.index = index
+	}
+}
+
+// WithWorkers 并发发送请求的协程数，默认 2
+func WithWorkers(n int) BulkOption {
+	return func(o *bulkOptions) {
+		o.workers = n
+	}
+}
+
+// WithFlushBytes 缓冲达到该字节数时发送，默认 5MB
+func WithFlushBytes(n int) BulkOption {
+	return func(o *bulkOptions) {
+		o.flushBytes = n
+	}
+}
+
+// WithFlushItems 缓冲达到该条数时发送，默认 1000
+func WithFlushItems(n int) BulkOption {
+	return func(o *bulkOptions) {
+		o.flushItems = n
+	}
+}
+
+// WithFlushInterval 缓冲未满时的最长等待时间，默认 5s，<= 0 时只按条数和大小刷新
+func WithFlushInterval(d time.Duration) BulkOption {
+	return func(o *bulkOptions) {
+		o.flushInterval = d
+	}
+}
+
+// WithBulkRetries 429 和 5xx 失败条目的最大重试次数和首次重试等待时间（之后每次翻倍），默认 3 次、200ms
+func WithBulkRetries(n int, backoff time.Duration) BulkOption {
+	return func(o *bulkOptions) {
+		o.maxRetries, o.retryBackoff = n, backoff
+	}
+}
+
+// WithBulkRefresh 每个 _bulk 请求的刷新策略，默认不刷新
+func WithBulkRefresh(refresh string) BulkOption {
+	return func(o *bulkOptions) {
+		o.refresh = refresh
+	}
+}
+
+// WithOnError 整个请求失败（网络错误、响应无法解析等）时的回调，对应条目会按重试规则处理
+func WithOnError(fn func(ctx context.Context, err error)) BulkOption {
+	return func(o *bulkOptions) {
+		o.onError = fn
+	}
+}
+
+// bulkEntry 已序列化的条目
+type bulkEntry struct {
+	item     BulkItem
+	ctx      context.Context
+	data     []byte // 元数据行和文档行
+	attempts int
+}
+
+// BulkIndexer 带缓冲的批量写入器，并发安全。条目按 flush 条件攒批发送，
+// 失败的条目按退避重试，每条结果通过条目上的回调通知
+type BulkIndexer struct {
+	client *Client
+	opts   bulkOptions
+	queue  chan *bulkEntry
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	added, succeeded, failed, retried, requests atomic.Uint64
+}
+
+// NewBulkIndexer 创建批量写入器，使用完毕必须调用 Close 发送剩余条目
+func (c *Client) NewBulkIndexer(opts ...BulkOption) *BulkIndexer {
+	o := bulkOptions{
+		workers:       2,
+		flushBytes:    5 << 20,
+		flushItems:    1000,
+		flushInterval: 5 * time.Second,
+		maxRetries:    3,
+		retryBackoff:  200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.workers = max(o.workers, 1)
+	b := &BulkIndexer{client: c, opts: o, queue: make(chan *bulkEntry, o.workers*64)}
+	if c.Enabled() {
+		for i := 0; i < o.workers; i++ {
+			b.wg.Add(1)
+			go b.worker()
+		}
+	}
+	return b
+}
+
+// Add 加入一条操作，缓冲队列满时阻塞直到 ctx 结束；ctx 同时传给该条目的回调
+func (b *BulkIndexer) Add(ctx context.Context, item BulkItem) error {
+	if item.Action == "" {
+		item.Action = BulkIndex
+	}
+	if item.Index == "" {
+		item.Index = b.opts.index
+	}
+	data, err := encodeBulkItem(item)
+	if err != nil {
+		return err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return errors.New("bulk indexer closed")
+	}
+	b.added.Add(1)
+	if !b.client.Enabled() {
+		return nil
+	}
+	select {
+	case b.queue <- &bulkEntry{item: item, ctx: context.WithoutCancel(ctx), data: data}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 停止接收新条目，发送缓冲中的条目并等待重试结束；ctx 结束时不再等待
+func (b *BulkIndexer) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats 返回当前统计
+func (b *BulkIndexer) Stats() BulkStats {
+	return BulkStats{
+		Added:     b.added.Load(),
+		Succeeded: b.succeeded.Load(),
+		Failed:    b.failed.Load(),
+		Retried:   b.retried.Load(),
+		Requests:  b.requests.Load(),
+	}
+}
+
+func (b *BulkIndexer) worker() {
+	defer b.wg.Done()
+	var (
+		batch []*bulkEntry
+		size  int
+	)
+	var tick <-chan time.Time
+	if b.opts.flushInterval > 0 {
+		ticker := time.NewTicker(b.opts.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	flush := func() {
+		if len(batch) > 0 {
+			b.flush(batch)
+			batch, size = nil, 0
+		}
+	}
+	for {
+		select {
+		case entry, ok := <-b.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			size += len(entry.data)
+			if size >= b.opts.flushBytes || len(batch) >= b.opts.flushItems {
+				flush()
+			}
+		case <-tick:
+			flush()
+		}
+	}
+}
+
+// flush 发送一批条目，可重试的失败条目在退避后重新发送，直到成功或超过重试次数
+func (b *BulkIndexer) flush(batch []*bulkEntry) {
+	backoff := b.opts.retryBackoff
+	for len(batch) > 0 {
+		results, err := b.send(batch)
+		var retry []*bulkEntry
+		for i, entry := range batch {
+			var res BulkItemResponse
+			itemErr := err
+			if err == nil {
+				res = results[i]
+				if res.Status < 300 {
+					b.succeeded.Add(1)
+					if entry.item.OnSuccess != nil {
+						entry.item.OnSuccess(entry.ctx, entry.item, res)
+					}
+					continue
+				}
+				itemErr = &ResponseError{Status: res.Status}
+				if res.Error != nil {
+					itemErr = &ResponseError{Status: res.Status, Type: res.Error.Type, Reason: res.Error.Reason}
+				}
+			}
+			if retryable(res.Status, err) && entry.attempts < b.opts.maxRetries {
+				entry.attempts++
+				b.retried.Add(1)
+				retry = append(retry, entry)
+				continue
+			}
+			b.failed.Add(1)
+			if entry.item.OnFailure != nil {
+				entry.item.OnFailure(entry.ctx, entry.item, res, itemErr)
+			}
+		}
+		if len(retry) > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		batch = retry
+	}
+}
+
+// retryable 请求级错误和 429、5xx 的条目可以重试
+func retryable(status int, err error) bool {
+	if err != nil {
+		var resErr *ResponseError
+		return !errors.As(err, &resErr) || resErr.Status == 429 || resErr.Status >= 500
+	}
+	return status == 429 || status >= 500
+}
+
+func (b *BulkIndexer) send(batch []*bulkEntry) ([]BulkItemResponse, error) {
+	var body bytes.Buffer
+	for _, entry := range batch {
+		body.Write(entry.data)
+	}
+	b.requests.Add(1)
+	ctx := context.Background()
+	opts := []func(*esapi.BulkRequest){b.client.Client.Bulk.WithContext(ctx)}
+	if b.opts.refresh != "" {
+		opts = append(opts, b.client.Client.Bulk.WithRefresh(b.opts.refresh))
+	}
+	res, err := b.client.Client.Bulk(&body, opts...)
+	if err == nil {
+		defer res.Body.Close()
+		if res.IsError() {
+			err = responseError(res)
+		}
+	}
+	var result struct {
+		Items []map[string]BulkItemResponse `json:"items"`
+	}
+	if err == nil {
+		if err = json.NewDecoder(res.Body).Decode(&result); err == nil && len(result.Items) != len(batch) {
+			err = fmt.Errorf("bulk response has %d items, want %d", len(result.Items), len(batch))
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("bulk request: %w", err)
+		if b.opts.onError != nil {
+			b.opts.onError(ctx, err)
+		}
+		return nil, err
+	}
+	responses := make([]BulkItemResponse, len(batch))
+	for i, item := range result.Items {
+		for _, r := range item {
+			responses[i] = r
+		}
+	}
+	return responses, nil
+}
+
+// encodeBulkItem 生成 _bulk 请求中的元数据行和文档行
+func encodeBulkItem(item BulkItem) ([]byte, error) {
+	meta := map[string]any{"_index": item.Index}
+	if item.ID != "" {
+		meta["_id"] = item.ID
+	}
+	if item.Version > 0 && item.Action != BulkUpdate {
+		meta["version"], meta["version_type"] = item.Version, "external"
+	}
+	var buf bytes.Buffer
+	line, err := json.Marshal(map[string]any{item.Action: meta})
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(line)
+	buf.WriteByte('\n')
+	switch item.Action {
+	case BulkDelete:
+		if item.ID == "" {
+			return nil, errors.New("bulk delete requires document id")
+		}
+		return buf.Bytes(), nil
+	case BulkIndex, BulkCreate:
+	case BulkUpdate:
+		if item.ID == "" {
+			return nil, errors.New("bulk update requires document id")
+		}
+		raw, err := docBytes(item.Doc)
+		if err != nil {
+			return nil, err
+		}
+		item.Doc = map[string]any{"doc": json.RawMessage(raw), "doc_as_upsert": item.Upsert}
+	default:
+		return nil, fmt.Errorf("unknown bulk action %q", item.Action)
+	}
+	doc, err := docBytes(item.Doc)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(doc)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// docBytes 把文档序列化为单行 JSON
+func docBytes(doc any) ([]byte, error) {
+	reader, err := jsonReader(doc)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err = buf.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+	var compact bytes.Buffer
+	if err = json.Compact(&compact, buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("invalid bulk document: %w", err)
+	}
+	return compact.Bytes(), nil
+}
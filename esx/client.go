@@ -1,9 +1,33 @@
 package esx
 
-import "github.com/elastic/go-elasticsearch/v8"
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
 
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/trancecho/open-sdk/config"
+)
+
+const (
+	defaultRetryBackoff   = 100 * time.Millisecond
+	maxRetryBackoff       = 10 * time.Second
+	defaultDialTimeout    = 5 * time.Second
+	defaultRequestTimeout = 30 * time.Second
+	defaultMaxIdleConns   = 10
+)
+
+// Client Elasticsearch 客户端，未启用时 TypedClient 和 Client 为 nil，所有操作为空操作
 type Client struct {
-	cfg         elasticsearch.Config
+	conf        Config
+	raw         *elasticsearch.Config // WithConfig 设置时直接使用
 	TypedClient *elasticsearch.TypedClient
 	Client      *elasticsearch.Client
 }
@@ -12,55 +36,187 @@ type Option func(*Client)
 
 var (
 	client *Client
+	mux    sync.RWMutex
 )
 
+// NewClient 创建客户端并设为默认客户端（GetClient 返回最近创建的客户端，与之前的行为一致），
+// 默认启用；通过 WithSettings 使用配置段时按其 Enable 决定是否启用。
+// 需要多个客户端时可在创建后用 SetClient 指定默认客户端
 func NewClient(opts ...Option) (*Client, error) {
-	client = &Client{}
+	c := &Client{conf: Config{Enable: true}}
 	for _, opt := range opts {
-		opt(client)
+		opt(c)
 	}
-	newClient, err := elasticsearch.NewTypedClient(client.cfg)
-	if err != nil {
+	if !c.conf.Enable {
+		SetClient(c)
+		return c, nil
+	}
+	cfg := c.raw
+	if cfg == nil {
+		built, err := buildConfig(c.conf)
+		if err != nil {
+			return nil, err
+		}
+		cfg = &built
+	}
+	var err error
+	if c.TypedClient, err = elasticsearch.NewTypedClient(*cfg); err != nil {
 		return nil, err
 	}
-	client.TypedClient = newClient
-	client.Client, err = elasticsearch.NewClient(client.cfg)
-	if err != nil {
+	if c.Client, err = elasticsearch.NewClient(*cfg); err != nil {
 		return nil, err
 	}
-	return client, nil
+	SetClient(c)
+	return c, nil
+}
+
+// WithSettings 使用完整的配置段，覆盖之前的选项
+func WithSettings(conf Config) Option {
+	return func(c *Client) {
+		c.conf = conf
+	}
 }
 
+// WithConfig 直接使用 go-elasticsearch 的配置，忽略其余连接选项
 func WithConfig(cfg elasticsearch.Config) Option {
 	return func(c *Client) {
-		c.cfg = cfg
+		c.raw = &cfg
 	}
 }
 
 func WithAddress(address []string) Option {
 	return func(c *Client) {
-		c.cfg.Addresses = address
+		c.conf.Addresses = address
 	}
 }
 
 func WithUsername(username string) Option {
 	return func(c *Client) {
-		c.cfg.Username = username
+		c.conf.Username = username
 	}
 }
 
 func WithPassword(password string) Option {
 	return func(c *Client) {
-		c.cfg.Password = password
+		c.conf.Password = password
 	}
 }
 
 func WithAPIKey(apiKey string) Option {
 	return func(c *Client) {
-		c.cfg.APIKey = apiKey
+		c.conf.APIKey = apiKey
 	}
 }
 
+// buildConfig 把配置段转换为 go-elasticsearch 配置，Addresses 为空时由 go-elasticsearch
+// 回退到 ELASTICSEARCH_URL 环境变量或 http://localhost:9200
+func buildConfig(conf Config) (elasticsearch.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: conf.InsecureSkipVerify}
+	if conf.CACert != "" {
+		pem, err := os.ReadFile(conf.CACert)
+		if err != nil {
+			return elasticsearch.Config{}, fmt.Errorf("read elasticsearch ca cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return elasticsearch.Config{}, fmt.Errorf("no certificate found in %s", conf.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	dialTimeout := orDefault(conf.DialTimeout, defaultDialTimeout)
+	maxIdle := conf.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = defaultMaxIdleConns
+	}
+	backoff := orDefault(conf.RetryBackoff, defaultRetryBackoff)
+	return elasticsearch.Config{
+		Addresses:    conf.Addresses,
+		Username:     conf.Username,
+		Password:     conf.Password,
+		APIKey:       conf.APIKey,
+		MaxRetries:   max(conf.MaxRetries, 0),
+		DisableRetry: conf.MaxRetries < 0,
+		RetryBackoff: func(attempt int) time.Duration {
+			d := backoff
+			for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+				d *= 2
+			}
+			return min(d, maxRetryBackoff)
+		},
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost:   maxIdle,
+			ResponseHeaderTimeout: orDefault(conf.RequestTimeout, defaultRequestTimeout),
+			DialContext:           (&net.Dialer{Timeout: dialTimeout}).DialContext,
+			TLSHandshakeTimeout:   dialTimeout,
+			TLSClientConfig:       tlsConfig,
+		},
+	}, nil
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// Enabled 客户端是否连接了集群
+func (c *Client) Enabled() bool {
+	return c != nil && c.Client != nil
+}
+
+// Ping 检查集群是否可用，未启用时返回 nil
+func (c *Client) Ping(ctx context.Context) error {
+	if !c.Enabled() {
+		return nil
+	}
+	res, err := c.Client.Ping(c.Client.Ping.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("ping elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res)
+	}
+	return nil
+}
+
+// InitElastic 按全局配置的 elasticsearch 段创建默认客户端并检查连通性，未启用时创建空操作客户端。
+// 连通性检查失败时恢复之前的默认客户端
+func InitElastic() error {
+	prev := GetClient()
+	c, err := NewClient(WithSettings(config.GetConfig().Elasticsearch))
+	if err != nil {
+		return fmt.Errorf("create elasticsearch client: %w", err)
+	}
+	if c.Enabled() {
+		ctx, cancel := context.WithTimeout(context.Background(), orDefault(c.conf.RequestTimeout, defaultRequestTimeout))
+		defer cancel()
+		if err = c.Ping(ctx); err != nil {
+			SetClient(prev)
+			return err
+		}
+		log.Printf("Connected to Elasticsearch at %s", c.conf.Addresses)
+	} else {
+		log.Println("Elasticsearch disabled")
+	}
+	return nil
+}
+
+// SetClient 设置默认客户端
+func SetClient(c *Client) {
+	mux.Lock()
+	defer mux.Unlock()
+	client = c
+}
+
+// GetClient 返回默认客户端，未初始化时返回空操作客户端
 func GetClient() *Client {
+	mux.RLock()
+	defer mux.RUnlock()
+	if client == nil {
+		return &Client{}
+	}
 	return client
 }
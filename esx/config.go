@@ -1,8 +1,6 @@
 package esx
 
-type Config struct {
-	Enable    bool     `yaml:"Enable"`
-	Addresses []string `yaml:"Addresses"`
-	Username  string   `yaml:"Username"`
-	Password  string   `yaml:"Password"`
-}
+import "github.com/trancecho/open-sdk/config"
+
+// Config 客户端配置，与全局配置中的 elasticsearch 段相同
+type Config = config.Elasticsearch
@@ -0,0 +1,51 @@
+package dbsync
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/trancecho/open-sdk/database/tenant"
+	"github.com/trancecho/open-sdk/esx"
+	"gorm.io/gorm"
+)
+
+// Backfill 按主键顺序分批读取模型 T 的整张表写入索引，用于首次建立索引或重建索引。
+// index 为空时使用注册时的索引，重建时可传入 Reindex 之前新建的版本索引。
+// 返回写入成功的文档数，有文档写入失败时返回错误
+func Backfill[T any](ctx context.Context, s *Syncer, db *gorm.DB, index string, batchSize int) (int64, error) {
+	b, ok := s.bindings[reflect.TypeOf((*T)(nil)).Elem()]
+	if !ok {
+		return 0, fmt.Errorf("dbsync: %T is not registered", *new(T))
+	}
+	if index == "" {
+		index = b.index
+	}
+	if batchSize <= 0 {
+		batchSize = s.batchSize
+	}
+	if _, err := primaryField(db, new(T)); err != nil {
+		return 0, err
+	}
+	toDocs := b.docs.(func(db *gorm.DB, list []T) map[string]any)
+	bulk := s.client.NewBulkIndexer(s.bulkOpts...)
+	var list []T
+	// FindInBatches 按主键顺序分批读取，读取时跨租户并应用注册时的 Scope
+	tx := db.WithContext(tenant.WithoutTenant(ctx)).Model(new(T)).Scopes(b.scope)
+	err := tx.FindInBatches(&list, batchSize, func(tx *gorm.DB, batch int) error {
+		for id, doc := range toDocs(tx, list) {
+			if err := bulk.Add(ctx, esx.BulkItem{Index: index, ID: id, Doc: doc}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if closeErr := bulk.Close(ctx); err == nil {
+		err = closeErr
+	}
+	stats := bulk.Stats()
+	if err == nil && stats.Failed > 0 {
+		err = fmt.Errorf("dbsync: %d documents failed to index", stats.Failed)
+	}
+	return int64(stats.Succeeded), err
+}
@@ -0,0 +1,418 @@
+package dbsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/trancecho/open-sdk/database"
+	"github.com/trancecho/open-sdk/database/tenant"
+	"github.com/trancecho/open-sdk/esx"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/dbresolver"
+)
+
+const (
+	callbackKey = "dbsync"
+	changedKey  = "dbsync:changed"
+)
+
+// Binding 模型到索引的映射
+type Binding[T any] struct {
+	Index string // 索引名或别名
+	// Doc 把模型转换为文档，返回 false 表示该记录不应出现在索引中（会被删除）；为 nil 时直接使用模型
+	Doc func(m *T) (doc any, ok bool)
+	// Scope 重新加载记录时使用的查询条件，如 Preload 关联
+	Scope func(db *gorm.DB) *gorm.DB
+}
+
+// binding 擦除类型参数后的映射
+type binding struct {
+	index string
+	scope func(db *gorm.DB) *gorm.DB
+	// load 按主键加载记录，返回 id => 文档，不存在或不应索引的 id 不在结果中
+	load func(db *gorm.DB, ids []any) (map[string]any, error)
+	// docs 为 func(db *gorm.DB, list []T) map[string]any，供 Backfill 转换已读取的记录
+	docs any
+}
+
+// Syncer 监听 gorm 的新增、更新、删除，把注册模型的变更同步到 Elasticsearch。
+// 记录变更只保存主键，同步时从数据库重新加载最新数据，因此重复或乱序的变更不影响最终结果。
+// 在 database.WithTx 的事务中的变更在提交后才同步；其他方式开启的事务中的变更可能在提交前同步
+type Syncer struct {
+	client        *esx.Client
+	flushInterval time.Duration
+	batchSize     int
+	bulkOpts      []esx.BulkOption
+	onFailure     func(index, id string, err error)
+
+	db       *gorm.DB
+	bulk     *esx.BulkIndexer
+	bindings map[reflect.Type]*binding
+
+	mu      sync.Mutex
+	pending map[reflect.Type]map[string]any // 模型 => id => 主键值
+	notify  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type Option func(*Syncer)
+
+// WithFlushInterval 变更的攒批间隔，默认 1s
+func WithFlushInterval(d time.Duration) Option {
+	return func(s *Syncer) {
+		s.flushInterval = d
+	}
+}
+
+// WithBatchSize 单次从数据库加载的最大记录数，默认 500
+func WithBatchSize(n int) Option {
+	return func(s *Syncer) {
+		s.batchSize = n
+	}
+}
+
+// WithBulkOptions 传给内部 BulkIndexer 的选项，刷新间隔默认与攒批间隔相同，工作协程数固定为 1 以保证同一文档的写入顺序
+func WithBulkOptions(opts ...esx.BulkOption) Option {
+	return func(s *Syncer) {
+		s.bulkOpts = append(s.bulkOpts, opts...)
+	}
+}
+
+// WithOnFailure 文档最终写入失败时的回调，默认记录日志。
+// 限流、集群错误等可重试的失败在回调后会重新登记，下次攒批时重新同步
+func WithOnFailure(fn func(index, id string, err error)) Option {
+	return func(s *Syncer) {
+		s.onFailure = fn
+	}
+}
+
+// New 创建同步器，client 为 nil 时使用 esx.GetClient。注册模型后通过 db.Use 启用：
+//
+//	s := dbsync.New(nil)
+//	dbsync.Register(s, dbsync.Binding[Post]{Index: "posts"})
+//	err := database.GetDb("MainMysql").Use(s)
+//	defer s.Close(ctx)
+func New(client *esx.Client, opts ...Option) *Syncer {
+	if client == nil {
+		client = esx.GetClient()
+	}
+	s := &Syncer{
+		client:        client,
+		flushInterval: time.Second,
+		batchSize:     500,
+		bindings:      make(map[reflect.Type]*binding),
+		pending:       make(map[reflect.Type]map[string]any),
+		notify:        make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		onFailure: func(index, id string, err error) {
+			log.Println("dbsync: sync", index, id, "failed:", err)
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register 注册需要同步的模型，需在 db.Use 之前调用
+func Register[T any](s *Syncer, b Binding[T]) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if b.Index == "" {
+		panic(fmt.Sprintf("dbsync: index of %s is empty", t))
+	}
+	scope := b.Scope
+	if scope == nil {
+		scope = func(db *gorm.DB) *gorm.DB { return db }
+	}
+	docs := func(db *gorm.DB, list []T) map[string]any {
+		return documents(db, list, b.Doc)
+	}
+	s.bindings[t] = &binding{
+		index: b.Index,
+		scope: scope,
+		docs:  docs,
+		load: func(db *gorm.DB, ids []any) (map[string]any, error) {
+			pk, err := primaryField(db, new(T))
+			if err != nil {
+				return nil, err
+			}
+			var list []T
+			err = db.Model(new(T)).Scopes(scope).
+				Where(clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Values: ids}).
+				Find(&list).Error
+			if err != nil {
+				return nil, err
+			}
+			return docs(db, list), nil
+		},
+	}
+}
+
+func documents[T any](db *gorm.DB, list []T, toDoc func(m *T) (any, bool)) map[string]any {
+	pk, err := primaryField(db, new(T))
+	if err != nil {
+		return nil
+	}
+	docs := make(map[string]any, len(list))
+	for i := range list {
+		m := &list[i]
+		var doc any = m
+		if toDoc != nil {
+			var ok bool
+			if doc, ok = toDoc(m); !ok {
+				continue
+			}
+		}
+		id, _ := pk.ValueOf(db.Statement.Context, reflect.ValueOf(m).Elem())
+		docs[fmt.Sprint(id)] = doc
+	}
+	return docs
+}
+
+func primaryField(db *gorm.DB, model any) (*schema.Field, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("dbsync: %s requires a single primary key", stmt.Schema.Name)
+	}
+	return stmt.Schema.PrioritizedPrimaryField, nil
+}
+
+// Enqueue 手动标记记录已变更，ids 为主键值，可用于消费发件箱等外部变更来源
+func Enqueue[T any](s *Syncer, ids ...any) {
+	s.enqueue(reflect.TypeOf((*T)(nil)).Elem(), ids)
+}
+
+func (s *Syncer) enqueue(t reflect.Type, ids []any) {
+	if len(ids) == 0 {
+		return
+	}
+	s.mu.Lock()
+	set := s.pending[t]
+	if set == nil {
+		set = make(map[string]any)
+		s.pending[t] = set
+	}
+	for _, id := range ids {
+		set[fmt.Sprint(id)] = id
+	}
+	full := len(set) >= s.batchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Syncer) Name() string {
+	return "open-sdk:dbsync"
+}
+
+// Initialize 注册回调并启动同步协程
+func (s *Syncer) Initialize(db *gorm.DB) error {
+	s.db = db.Session(&gorm.Session{NewDB: true}).Clauses(dbresolver.Write)
+	opts := append([]esx.BulkOption{esx.WithFlushInterval(s.flushInterval)}, s.bulkOpts...)
+	s.bulk = s.client.NewBulkIndexer(append(opts, esx.WithWorkers(1))...)
+	cb := db.Callback()
+	for _, reg := range []struct {
+		name string
+		fn   func(string, func(*gorm.DB)) error
+		cb   func(*gorm.DB)
+	}{
+		{"after_create", cb.Create().After("gorm:commit_or_rollback_transaction").Register, s.afterCreate},
+		{"before_update", cb.Update().Before("gorm:update").Register, s.collect},
+		{"after_update", cb.Update().After("gorm:commit_or_rollback_transaction").Register, s.afterChange},
+		{"before_delete", cb.Delete().Before("gorm:delete").Register, s.collect},
+		{"after_delete", cb.Delete().After("gorm:commit_or_rollback_transaction").Register, s.afterChange},
+	} {
+		if err := reg.fn(callbackKey+":"+reg.name, reg.cb); err != nil {
+			return err
+		}
+	}
+	go s.run()
+	return nil
+}
+
+func (s *Syncer) bound(db *gorm.DB) (reflect.Type, bool) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.PrioritizedPrimaryField == nil {
+		return nil, false
+	}
+	t := db.Statement.Schema.ModelType
+	_, ok := s.bindings[t]
+	return t, ok
+}
+
+func (s *Syncer) afterCreate(db *gorm.DB) {
+	t, ok := s.bound(db)
+	if !ok {
+		return
+	}
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	var ids []any
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if id, zero := pk.ValueOf(db.Statement.Context, reflect.Indirect(rv.Index(i))); !zero {
+				ids = append(ids, id)
+			}
+		}
+	case reflect.Struct:
+		if id, zero := pk.ValueOf(db.Statement.Context, rv); !zero {
+			ids = append(ids, id)
+		}
+	}
+	s.afterCommit(db, t, ids)
+}
+
+// collect 在更新、删除前找出受影响记录的主键
+func (s *Syncer) collect(db *gorm.DB) {
+	if _, ok := s.bound(db); !ok {
+		return
+	}
+	stmt := db.Statement
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		if id, zero := pk.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			db.InstanceSet(changedKey, []any{id})
+			return
+		}
+	}
+	where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where)
+	if !ok || len(where.Exprs) == 0 {
+		return
+	}
+	var ids []any
+	err := db.Session(&gorm.Session{NewDB: true}).Clauses(dbresolver.Write).Table(stmt.Table).
+		Clauses(where).Pluck(pk.DBName, &ids).Error
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	db.InstanceSet(changedKey, ids)
+}
+
+func (s *Syncer) afterChange(db *gorm.DB) {
+	t, ok := s.bound(db)
+	if !ok {
+		return
+	}
+	if ids, ok := db.InstanceGet(changedKey); ok {
+		s.afterCommit(db, t, ids.([]any))
+	}
+}
+
+// afterCommit 在 database.WithTx 的事务提交后登记变更，不在事务中时立即登记
+func (s *Syncer) afterCommit(db *gorm.DB, t reflect.Type, ids []any) {
+	if len(ids) == 0 {
+		return
+	}
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	database.AfterCommit(ctx, func(context.Context) {
+		s.enqueue(t, ids)
+	})
+}
+
+func (s *Syncer) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			s.flush()
+			return
+		case <-ticker.C:
+		case <-s.notify:
+		}
+		s.flush()
+	}
+}
+
+// flush 加载所有待同步记录并写入批量写入器，加载失败的记录留待下次重试
+func (s *Syncer) flush() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[reflect.Type]map[string]any)
+	s.mu.Unlock()
+
+	// 同步与请求无关，需要读取所有租户的数据
+	ctx := tenant.WithoutTenant(context.Background())
+	for t, set := range pending {
+		b := s.bindings[t]
+		ids := make([]any, 0, len(set))
+		for _, id := range set {
+			ids = append(ids, id)
+		}
+		for start := 0; start < len(ids); start += s.batchSize {
+			chunk := ids[start:min(start+s.batchSize, len(ids))]
+			docs, err := b.load(s.db.WithContext(ctx), chunk)
+			if err != nil {
+				log.Println("dbsync: load", t, "failed, retry later:", err)
+				s.enqueue(t, chunk)
+				continue
+			}
+			for _, id := range chunk {
+				key := fmt.Sprint(id)
+				item := esx.BulkItem{Index: b.index, ID: key, OnFailure: s.failed(t, id)}
+				if doc, ok := docs[key]; ok {
+					item.Doc = doc
+				} else {
+					item.Action = esx.BulkDelete
+				}
+				if err = s.bulk.Add(ctx, item); err != nil {
+					log.Println("dbsync: add", b.index, key, "failed:", err)
+				}
+			}
+		}
+	}
+}
+
+// failed 返回写入失败的回调：通知 onFailure，可重试的失败重新登记，避免索引与数据库长期不一致
+func (s *Syncer) failed(t reflect.Type, id any) func(context.Context, esx.BulkItem, esx.BulkItemResponse, error) {
+	return func(_ context.Context, item esx.BulkItem, res esx.BulkItemResponse, err error) {
+		// 删除不存在的文档视为成功
+		if item.Action == esx.BulkDelete && res.Status == 404 {
+			return
+		}
+		s.onFailure(item.Index, item.ID, err)
+		var resErr *esx.ResponseError
+		if !errors.As(err, &resErr) || resErr.Status == 429 || resErr.Status >= 500 {
+			s.enqueue(t, []any{id})
+		}
+	}
+}
+
+// Close 同步剩余变更并关闭批量写入器
+func (s *Syncer) Close(ctx context.Context) error {
+	if s.bulk == nil {
+		return nil
+	}
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.bulk.Close(ctx)
+}
@@ -0,0 +1,90 @@
+package esx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+)
+
+var (
+	// ErrDisabled 客户端未启用，读操作返回该错误，写操作直接忽略
+	ErrDisabled = errors.New("elasticsearch disabled")
+	// ErrNotFound 文档或索引不存在
+	ErrNotFound = errors.New("elasticsearch: not found")
+	// ErrAliasConflict 已有与别名同名的具体索引，需先迁移到版本索引并删除后才能使用别名
+	ErrAliasConflict = errors.New("elasticsearch: index exists with the alias name")
+)
+
+// ResponseError 集群返回的错误响应
+type ResponseError struct {
+	Status int
+	Type   string
+	Reason string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("elasticsearch error %d: %s: %s", e.Status, e.Type, e.Reason)
+}
+
+// Is 使 404 响应满足 errors.Is(err, ErrNotFound)
+func (e *ResponseError) Is(target error) bool {
+	return target == ErrNotFound && e.Status == 404
+}
+
+// responseError 从错误响应中解析错误类型和原因
+func responseError(res *esapi.Response) error {
+	return parseError(res.StatusCode, res.Body)
+}
+
+// httpError 同 responseError，用于 TypedClient 的 Perform 返回的响应
+func httpError(res *http.Response) error {
+	return parseError(res.StatusCode, res.Body)
+}
+
+func parseError(status int, r io.Reader) error {
+	e := &ResponseError{Status: status}
+	data, _ := io.ReadAll(r)
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && len(body.Error) > 0 {
+		var detail struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		}
+		if json.Unmarshal(body.Error, &detail) == nil && detail.Type != "" {
+			e.Type, e.Reason = detail.Type, detail.Reason
+		} else {
+			_ = json.Unmarshal(body.Error, &e.Reason)
+		}
+	}
+	if e.Type == "" && e.Status == 404 {
+		// 文档不存在时响应体中没有 error 字段
+		e.Type, e.Reason = "not_found", "not found"
+	}
+	if e.Reason == "" {
+		e.Reason = string(data)
+	}
+	return e
+}
+
+// typedError 把 TypedClient 返回的错误转换为 ResponseError
+func typedError(err error) error {
+	var esErr *types.ElasticsearchError
+	if !errors.As(err, &esErr) {
+		return err
+	}
+	e := &ResponseError{Status: esErr.Status, Type: esErr.ErrorCause.Type}
+	if esErr.ErrorCause.Reason != nil {
+		e.Reason = *esErr.ErrorCause.Reason
+	}
+	if e.Type == "" && e.Status == 404 {
+		e.Type, e.Reason = "not_found", "not found"
+	}
+	return e
+}
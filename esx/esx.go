@@ -1,237 +1,191 @@
 package esx
 
 import (
+	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"github.com/elastic/go-elasticsearch/v8"
-	"github.com/elastic/go-elasticsearch/v8/esapi"
-	"github.com/trancecho/open-sdk/config"
-	"log"
-	"net"
-	"net/http"
+	"io"
 	"strings"
-	"time"
-)
 
-var (
-	Es *elasticsearch.Client
+	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
-func InitElastic() {
-	addresses := config.GetConfig().Elasticsearch.Addresses
-	//addresses := []string{"http://localhost:9200"}
-	cfg := elasticsearch.Config{
-		Addresses: addresses,
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost:   10,
-			ResponseHeaderTimeout: time.Second,
-			DialContext:           (&net.Dialer{Timeout: time.Second}).DialContext,
-			TLSClientConfig: &tls.Config{
-				MinVersion: tls.VersionTLS12,
-			},
-		},
-	}
+// SearchResponse 搜索结果
+type SearchResponse struct {
+	Total int64
+	Hits  []Hit
+}
 
-	var err error
-	Es, err = elasticsearch.NewClient(cfg)
-	if err != nil {
-		log.Fatalf("Error creating the client: %s", err)
-	}
-	log.Printf("Connected to Elasticsearch at %s", cfg.Addresses)
+// Hit 一条命中的文档
+type Hit struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Score  float64         `json:"_score"`
+	Source json.RawMessage `json:"_source"`
+	Sort   []any           `json:"sort,omitempty"`
 }
 
-func CreateIndex(index string) (exist bool) {
-	// 检查索引是否存在
-	exists, err := Es.Indices.Exists([]string{index})
+// CreateIndex 创建索引，body 为可选的 settings/mappings，索引已存在时返回 created=false
+func (c *Client) CreateIndex(ctx context.Context, index string, body any) (created bool, err error) {
+	if !c.Enabled() {
+		return false, nil
+	}
+	res, err := c.Client.Indices.Exists([]string{index}, c.Client.Indices.Exists.WithContext(ctx))
 	if err != nil {
-		log.Fatalf("Error checking if index exists: %s", err)
+		return false, fmt.Errorf("check index %s: %w", index, err)
 	}
-	if exists.StatusCode == 200 {
-		log.Printf("Index %s already exists", index)
-		return true
+	res.Body.Close()
+	if res.StatusCode == 200 {
+		return false, nil
 	}
-
-	// 创建索引
-	_, err = Es.Indices.Create(index)
-
+	opts := []func(*esapi.IndicesCreateRequest){c.Client.Indices.Create.WithContext(ctx)}
+	if body != nil {
+		reader, err := jsonReader(body)
+		if err != nil {
+			return false, err
+		}
+		opts = append(opts, c.Client.Indices.Create.WithBody(reader))
+	}
+	res, err = c.Client.Indices.Create(index, opts...)
 	if err != nil {
-		log.Fatalf("Error creating index: %s", err)
+		return false, fmt.Errorf("create index %s: %w", index, err)
 	}
-	log.Printf("Index %s created", index)
-	return false
+	defer res.Body.Close()
+	if res.IsError() {
+		return false, responseError(res)
+	}
+	return true, nil
 }
 
-func IndexDocument(index, body string) error {
-	req := esapi.IndexRequest{
-		Index:   index,
-		Body:    strings.NewReader(body),
-		Refresh: "true",
+// IndexDocument 写入文档，id 为空时由集群生成，返回文档 id
+func (c *Client) IndexDocument(ctx context.Context, index, id string, doc any) (string, error) {
+	if !c.Enabled() {
+		return id, nil
 	}
-	res, err := req.Do(context.Background(), Es)
+	body, err := jsonReader(doc)
 	if err != nil {
-		log.Printf("Error indexing document to index %s: %s", index, err)
-		return err
+		return "", err
 	}
-	defer func() {
-		err := res.Body.Close()
-		if err != nil {
-			log.Printf("Error closing response body: %s", err)
-		}
-	}()
-
-	if res.IsError() {
-		log.Printf("Error response from server: %s", res.String())
-		return fmt.Errorf("error indexing document to index %s: %s", index, res.String())
+	req := esapi.IndexRequest{Index: index, DocumentID: id, Body: body, Refresh: c.conf.Refresh}
+	var result struct {
+		ID string `json:"_id"`
 	}
-
-	log.Printf("Document indexed in index %s: %s", index, res.String())
-	return nil
+	if err = c.do(ctx, req, &result); err != nil {
+		return "", fmt.Errorf("index document to %s: %w", index, err)
+	}
+	return result.ID, nil
 }
 
-func GetDocument(index, id string) (result any, err error) {
-	req := esapi.GetRequest{
-		Index:      index,
-		DocumentID: id,
+// GetDocument 读取文档原文，文档不存在时返回 ErrNotFound
+func (c *Client) GetDocument(ctx context.Context, index, id string) (json.RawMessage, error) {
+	if !c.Enabled() {
+		return nil, ErrDisabled
 	}
-	res, err := req.Do(context.Background(), Es)
-	if err != nil {
-		log.Fatalf("Error getting document: %s", err)
+	var result struct {
+		Source json.RawMessage `json:"_source"`
 	}
-	defer res.Body.Close()
-	var r map[string]interface{}
-	if res.IsError() {
-		log.Printf("Document %s not found in index %s", id, index)
-		return nil, fmt.Errorf("document not found in index %s", index)
-	} else {
-
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-			return nil, fmt.Errorf("error parsing the response body: %w", err)
-		}
-		log.Printf("Document %s retrieved from index %s: %s", id, index, r["_source"])
+	if err := c.do(ctx, esapi.GetRequest{Index: index, DocumentID: id}, &result); err != nil {
+		return nil, fmt.Errorf("get document %s/%s: %w", index, id, err)
 	}
-	return r["_source"], nil
+	return result.Source, nil
 }
 
-func SearchDocuments(index, query string) (interface{}, error) {
-	var buf strings.Builder
-	// Construct the search query as JSON with fuzziness
-	searchQuery := map[string]interface{}{
-		"query": map[string]interface{}{
-			"match": map[string]interface{}{
-				"content": map[string]interface{}{
-					"query":     query,
-					"fuzziness": "AUTO", // 自动处理模糊度
-				},
-			},
-		},
-	}
-	// Encode the query to JSON
-	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
-		return nil, fmt.Errorf("error encoding search query: %w", err)
+// UpdateDocument 部分更新文档，doc 中的字段合并到原文档
+func (c *Client) UpdateDocument(ctx context.Context, index, id string, doc any) error {
+	if !c.Enabled() {
+		return nil
 	}
-
-	// Perform the search request
-	res, err := Es.Search(
-		Es.Search.WithContext(context.Background()),
-		Es.Search.WithIndex(index),
-		Es.Search.WithBody(strings.NewReader(buf.String())),
-		Es.Search.WithTrackTotalHits(true),
-		Es.Search.WithPretty(),
-	)
+	body, err := jsonReader(map[string]any{"doc": doc})
 	if err != nil {
-		return nil, fmt.Errorf("error searching documents: %w", err)
+		return err
 	}
-	defer res.Body.Close()
-
-	// Check if the response has an error
-	if res.IsError() {
-		var esErr map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&esErr); err != nil {
-			return nil, fmt.Errorf("error parsing error response: %w", err)
-		}
-		errorType := esErr["error"].(map[string]interface{})["type"]
-		errorReason := esErr["error"].(map[string]interface{})["reason"]
-		return nil, fmt.Errorf("search error: %s, reason: %s", errorType, errorReason)
+	req := esapi.UpdateRequest{Index: index, DocumentID: id, Body: body, Refresh: c.conf.Refresh}
+	if err = c.do(ctx, req, nil); err != nil {
+		return fmt.Errorf("update document %s/%s: %w", index, id, err)
 	}
+	return nil
+}
 
-	// Decode the response
-	var r map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return nil, fmt.Errorf("error parsing the response body: %w", err)
+// DeleteDocument 删除文档，文档不存在时返回 ErrNotFound
+func (c *Client) DeleteDocument(ctx context.Context, index, id string) error {
+	if !c.Enabled() {
+		return nil
 	}
-
-	// Return search hits
-	return r["hits"], nil
+	req := esapi.DeleteRequest{Index: index, DocumentID: id, Refresh: c.conf.Refresh}
+	if err := c.do(ctx, req, nil); err != nil {
+		return fmt.Errorf("delete document %s/%s: %w", index, id, err)
+	}
+	return nil
 }
 
-func UpdateDocument(index, id, body string) (bool, error) {
-	req := esapi.UpdateRequest{
-		Index:      index,
-		DocumentID: id,
-		Body:       strings.NewReader(body), // 使用 strings.NewReader
-		Refresh:    "true",
-	}
-	res, err := req.Do(context.Background(), Es)
+// Search 执行搜索，body 为完整的查询 DSL
+func (c *Client) Search(ctx context.Context, index string, body any) (*SearchResponse, error) {
+	if !c.Enabled() {
+		return nil, ErrDisabled
+	}
+	reader, err := jsonReader(body)
 	if err != nil {
-		log.Fatalf("Error updating document: %s", err)
+		return nil, err
 	}
-	defer res.Body.Close()
-	if res.IsError() {
-		return false, fmt.Errorf("error updating document: %s", res.String())
+	req := esapi.SearchRequest{Index: []string{index}, Body: reader, TrackTotalHits: true}
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []Hit `json:"hits"`
+		} `json:"hits"`
 	}
-	log.Printf("Document %s updated in index %s", id, index)
-	return true, nil
+	if err = c.do(ctx, req, &result); err != nil {
+		return nil, fmt.Errorf("search %s: %w", index, err)
+	}
+	return &SearchResponse{Total: result.Hits.Total.Value, Hits: result.Hits.Hits}, nil
 }
 
-func DeleteDocument(index, id string) error {
-	req := esapi.DeleteRequest{
-		Index:      index,
-		DocumentID: id,
-		Refresh:    "true",
+// SearchDocuments 在 fields 上模糊匹配 query，未指定字段时使用 content 字段
+func (c *Client) SearchDocuments(ctx context.Context, index, query string, fields ...string) (*SearchResponse, error) {
+	if len(fields) == 0 {
+		fields = []string{"content"}
 	}
-	res, err := req.Do(context.Background(), Es)
+	q := RawQuery{"multi_match": map[string]any{"query": query, "fields": fields, "fuzziness": "AUTO"}}
+	return c.Search(ctx, index, NewSearch().Query(q))
+}
+
+// do 执行请求，错误响应转换为 ResponseError，result 非 nil 时解析响应体
+func (c *Client) do(ctx context.Context, req esapi.Request, result any) error {
+	res, err := req.Do(ctx, c.Client)
 	if err != nil {
-		return fmt.Errorf("error deleting document: %s", err)
+		return err
 	}
 	defer res.Body.Close()
 	if res.IsError() {
-		return fmt.Errorf("error deleting document: %s", res.String())
+		return responseError(res)
+	}
+	if result == nil {
+		return nil
+	}
+	if err = json.NewDecoder(res.Body).Decode(result); err != nil {
+		return fmt.Errorf("error parsing the response body: %w", err)
 	}
-	log.Printf("Document %s deleted from index %s", id, index)
 	return nil
 }
 
-func main() {
-	InitElastic()
-	indexName := "mama"
-
-	// 创建索引
-	CreateIndex(indexName)
-	//
-	//// 索引文档
-	//docID := "1"
-	//docBody := `{"title": "Test Document", "content": "This is a test document."}`
-	//// 添加行
-	//IndexDocument(indexName, docID, docBody)
-	//
-	//// 获取文档
-	//GetDocument(indexName, docID)
-	////
-	//// 搜索文档
-	//SearchDocuments(indexName, "test")
-	//
-	//// 更新文档
-	//updateBody := `{"doc": {"content": "This is an updated test document."}}`
-	//UpdateDocument(indexName, docID, updateBody)
-	//
-	//// 获取更新后的文档
-	//GetDocument(indexName, docID)
-	//
-	//// 删除文档
-	//DeleteDocument(indexName, docID)
-	//
-	//// 尝试获取已删除的文档
-	//GetDocument(indexName, docID)
+// jsonReader 把文档转换为请求体：string、[]byte、json.RawMessage 和 io.Reader 原样使用，其余按 JSON 序列化
+func jsonReader(v any) (io.Reader, error) {
+	switch x := v.(type) {
+	case io.Reader:
+		return x, nil
+	case string:
+		return strings.NewReader(x), nil
+	case []byte:
+		return bytes.NewReader(x), nil
+	case json.RawMessage:
+		return bytes.NewReader(x), nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request body: %w", err)
+	}
+	return bytes.NewReader(data), nil
 }
@@ -0,0 +1,719 @@
+package esxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type searchRequest struct {
+	Query       map[string]any  `json:"query"`
+	From        int             `json:"from"`
+	Size        *int            `json:"size"`
+	Sort        json.RawMessage `json:"sort"`
+	SearchAfter []any           `json:"search_after"`
+	Source      json.RawMessage `json:"_source"`
+	Suggest     map[string]any  `json:"suggest"`
+}
+
+type hit struct {
+	index  string
+	id     string
+	doc    *document
+	fields map[string]any // 文档字段，含映射中声明的多字段
+	sort   []any
+}
+
+type sortField struct {
+	field string
+	desc  bool
+}
+
+func (s *Server) search(name string, body []byte) (int, any) {
+	var req searchRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return errorBody(400, "parsing_exception", err.Error())
+		}
+	}
+	hits, status, res := s.match(name, req.Query)
+	if status != 0 {
+		return status, res
+	}
+	sorts, err := parseSort(req.Sort)
+	if err != nil {
+		return errorBody(400, "parsing_exception", err.Error())
+	}
+	for i := range hits {
+		for _, sf := range sorts {
+			hits[i].sort = append(hits[i].sort, sortValue(hits[i], sf.field))
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if c := compareSort(hits[i].sort, hits[j].sort, sorts); c != 0 {
+			return c < 0
+		}
+		return hits[i].doc.seqNo < hits[j].doc.seqNo
+	})
+	total := len(hits)
+	if len(req.SearchAfter) > 0 {
+		if len(req.SearchAfter) != len(sorts) {
+			return errorBody(400, "illegal_argument_exception", "search_after has "+strconv.Itoa(len(req.SearchAfter))+" value(s) but sort has "+strconv.Itoa(len(sorts)))
+		}
+		after := hits[:0]
+		for _, h := range hits {
+			if compareSort(h.sort, req.SearchAfter, sorts) > 0 {
+				after = append(after, h)
+			}
+		}
+		hits = after
+	}
+	size := 10
+	if req.Size != nil {
+		size = *req.Size
+	}
+	from := min(max(req.From, 0), len(hits))
+	hits = hits[from:min(from+max(size, 0), len(hits))]
+
+	filter, err := parseSourceFilter(req.Source)
+	if err != nil {
+		return errorBody(400, "parsing_exception", err.Error())
+	}
+	list := make([]any, 0, len(hits))
+	for _, h := range hits {
+		item := map[string]any{"_index": h.index, "_id": h.id, "_score": 1.0}
+		if len(sorts) > 0 {
+			item["sort"], item["_score"] = h.sort, nil
+		}
+		if src := filter.apply(h.doc); src != nil {
+			item["_source"] = src
+		}
+		list = append(list, item)
+	}
+	var maxScore any
+	if len(list) > 0 && len(sorts) == 0 {
+		maxScore = 1.0
+	}
+	result := map[string]any{
+		"took":      1,
+		"timed_out": false,
+		"_shards":   map[string]any{"total": 1, "successful": 1, "skipped": 0, "failed": 0},
+		"hits": map[string]any{
+			"total":     map[string]any{"value": total, "relation": "eq"},
+			"max_score": maxScore,
+			"hits":      list,
+		},
+	}
+	if len(req.Suggest) > 0 {
+		suggest, err := s.suggest(name, req.Suggest)
+		if err != nil {
+			return errorBody(400, "illegal_argument_exception", err.Error())
+		}
+		result["suggest"] = suggest
+	}
+	return 200, result
+}
+
+func (s *Server) count(name string, body []byte) (int, any) {
+	var req searchRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return errorBody(400, "parsing_exception", err.Error())
+		}
+	}
+	hits, status, res := s.match(name, req.Query)
+	if status != 0 {
+		return status, res
+	}
+	return 200, map[string]any{"count": len(hits), "_shards": shards()["_shards"]}
+}
+
+// match 返回 name 对应索引中满足查询的文档，按写入顺序排列
+func (s *Server) match(name string, query map[string]any) ([]hit, int, any) {
+	names := s.resolve(name)
+	if len(names) == 0 && !strings.Contains(name, "*") && name != "_all" {
+		status, res := indexNotFound(name)
+		return nil, status, res
+	}
+	var hits []hit
+	for _, n := range names {
+		multi := multiFields(s.indices[n].body)
+		for id, doc := range s.indices[n].docs {
+			fields := withMultiFields(doc.fields, multi)
+			ok, err := matches(query, id, fields)
+			if err != nil {
+				status, res := errorBody(400, "parsing_exception", err.Error())
+				return nil, status, res
+			}
+			if ok {
+				hits = append(hits, hit{index: n, id: id, doc: doc, fields: fields})
+			}
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].doc.seqNo < hits[j].doc.seqNo })
+	return hits, 0, nil
+}
+
+// matches 判断文档是否满足查询，空查询等同 match_all
+func matches(query map[string]any, id string, doc map[string]any) (bool, error) {
+	if len(query) == 0 {
+		return true, nil
+	}
+	if len(query) != 1 {
+		return false, fmt.Errorf("query malformed, expected a single query type")
+	}
+	for typ, raw := range query {
+		body, _ := raw.(map[string]any)
+		switch typ {
+		case "match_all":
+			return true, nil
+		case "match_none":
+			return false, nil
+		case "bool":
+			return matchBool(body, id, doc)
+		case "ids":
+			for _, v := range asList(body["values"]) {
+				if fmt.Sprint(v) == id {
+					return true, nil
+				}
+			}
+			return false, nil
+		case "exists":
+			return len(lookup(doc, fmt.Sprint(body["field"]))) > 0, nil
+		case "term", "terms", "prefix", "wildcard", "range", "match", "match_phrase", "match_phrase_prefix", "match_bool_prefix":
+			field, arg, err := singleField(typ, body)
+			if err != nil {
+				return false, err
+			}
+			return matchField(typ, lookup(doc, field), arg)
+		case "multi_match":
+			fields := asList(body["fields"])
+			if len(fields) == 0 {
+				fields = []any{"*"}
+			}
+			var values []any
+			for _, f := range fields {
+				field, _, _ := strings.Cut(fmt.Sprint(f), "^")
+				if strings.Contains(field, "*") {
+					values = append(values, lookupPattern(doc, field)...)
+				} else {
+					values = append(values, lookup(doc, field)...)
+				}
+			}
+			kind := "match"
+			switch body["type"] {
+			case "phrase":
+				kind = "match_phrase"
+			case "phrase_prefix":
+				kind = "match_phrase_prefix"
+			case "bool_prefix":
+				kind = "match_bool_prefix"
+			}
+			return matchField(kind, values, body)
+		default:
+			return false, fmt.Errorf("esxtest: unsupported query [%s]", typ)
+		}
+	}
+	return false, nil
+}
+
+func matchBool(body map[string]any, id string, doc map[string]any) (bool, error) {
+	all := func(key string, want bool) (bool, error) {
+		for _, q := range asList(body[key]) {
+			qm, _ := q.(map[string]any)
+			ok, err := matches(qm, id, doc)
+			if err != nil || ok != want {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	for _, c := range []struct {
+		key  string
+		want bool
+	}{{"must", true}, {"filter", true}, {"must_not", false}} {
+		if ok, err := all(c.key, c.want); !ok || err != nil {
+			return false, err
+		}
+	}
+	should := asList(body["should"])
+	if len(should) == 0 {
+		return true, nil
+	}
+	minimum := 0
+	if len(asList(body["must"])) == 0 && len(asList(body["filter"])) == 0 {
+		minimum = 1
+	}
+	if v, ok := body["minimum_should_match"]; ok {
+		n, err := strconv.Atoi(strings.TrimSuffix(fmt.Sprint(v), "%"))
+		if err != nil {
+			return false, fmt.Errorf("esxtest: unsupported minimum_should_match [%v]", v)
+		}
+		if strings.HasSuffix(fmt.Sprint(v), "%") {
+			n = len(should) * n / 100
+		}
+		minimum = n
+	}
+	matched := 0
+	for _, q := range should {
+		qm, _ := q.(map[string]any)
+		ok, err := matches(qm, id, doc)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			matched++
+		}
+	}
+	return matched >= minimum, nil
+}
+
+// singleField 解析 {field: arg} 形式的查询体
+func singleField(typ string, body map[string]any) (string, any, error) {
+	for k, v := range body {
+		if k == "boost" || k == "_name" {
+			continue
+		}
+		return k, v, nil
+	}
+	return "", nil, fmt.Errorf("[%s] query malformed, no field specified", typ)
+}
+
+func matchField(typ string, values []any, arg any) (bool, error) {
+	opts, _ := arg.(map[string]any)
+	value := arg
+	if opts != nil {
+		for _, k := range []string{"value", "query", "values"} {
+			if v, ok := opts[k]; ok {
+				value = v
+				break
+			}
+		}
+	}
+	switch typ {
+	case "term":
+		return containsValue(values, value, opts["case_insensitive"] == true), nil
+	case "terms":
+		for _, v := range asList(value) {
+			if containsValue(values, v, false) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "prefix":
+		for _, v := range values {
+			if strings.HasPrefix(fmt.Sprint(v), fmt.Sprint(value)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "wildcard":
+		for _, v := range values {
+			if ok, _ := path.Match(fmt.Sprint(value), fmt.Sprint(v)); ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "range":
+		for _, v := range values {
+			if inRange(v, opts) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	// match 系列：按小写词元比较，不做词干提取和模糊匹配；match_bool_prefix 的最后一个词按前缀匹配
+	query := tokenize(fmt.Sprint(value))
+	if len(query) == 0 {
+		return false, nil
+	}
+	for _, v := range values {
+		text := tokenize(fmt.Sprint(v))
+		switch typ {
+		case "match_phrase":
+			if indexOf(text, query, false) >= 0 {
+				return true, nil
+			}
+		case "match_phrase_prefix":
+			if indexOf(text, query, true) >= 0 {
+				return true, nil
+			}
+		default:
+			set := make(map[string]bool, len(text))
+			for _, t := range text {
+				set[t] = true
+			}
+			hits := 0
+			for i, t := range query {
+				if set[t] || typ == "match_bool_prefix" && i == len(query)-1 && hasPrefix(text, t) {
+					hits++
+				}
+			}
+			if opts["operator"] == "and" || opts["operator"] == "AND" {
+				if hits == len(query) {
+					return true, nil
+				}
+			} else if hits > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func hasPrefix(tokens []string, prefix string) bool {
+	for _, t := range tokens {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// indexOf 返回 phrase 在 text 中连续出现的位置，prefix 为 true 时最后一个词按前缀匹配
+func indexOf(text, phrase []string, prefix bool) int {
+	for i := 0; i+len(phrase) <= len(text); i++ {
+		ok := true
+		for j, t := range phrase {
+			last := j == len(phrase)-1
+			if text[i+j] != t && !(prefix && last && strings.HasPrefix(text[i+j], t)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsValue(values []any, want any, fold bool) bool {
+	w := fmt.Sprint(want)
+	for _, v := range values {
+		got := fmt.Sprint(v)
+		if got == w || fold && strings.EqualFold(got, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func inRange(v any, opts map[string]any) bool {
+	for op, bound := range opts {
+		var c int
+		switch op {
+		case "gt", "gte", "lt", "lte":
+			c = compareValues(v, bound)
+		default:
+			continue
+		}
+		if op == "gt" && c <= 0 || op == "gte" && c < 0 || op == "lt" && c >= 0 || op == "lte" && c > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues 数字按数值比较，其他按字符串比较（ISO 8601 时间可直接比较）
+func compareValues(a, b any) int {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func asList(v any) []any {
+	switch l := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return l
+	default:
+		return []any{l}
+	}
+}
+
+// lookup 按点分路径取字段值，数组展开；field.keyword 等子字段视为原字段
+func lookup(doc map[string]any, field string) []any {
+	if vs := lookupPath(doc, strings.Split(field, ".")); len(vs) > 0 {
+		return vs
+	}
+	// 动态映射为字符串生成的 keyword 子字段，其余多字段由 withMultiFields 按映射补充
+	if parent, ok := strings.CutSuffix(field, ".keyword"); ok {
+		return lookupPath(doc, strings.Split(parent, "."))
+	}
+	return nil
+}
+
+// multiFields 从索引映射中收集多字段路径及其所属字段，如 title.raw => title；
+// search_as_you_type 字段自动带有 _2gram、_3gram、_index_prefix 子字段
+func multiFields(body json.RawMessage) map[string]string {
+	var spec struct {
+		Mappings map[string]any `json:"mappings"`
+	}
+	if json.Unmarshal(body, &spec) != nil {
+		return nil
+	}
+	multi := make(map[string]string)
+	var walk func(props map[string]any, prefix string)
+	walk = func(props map[string]any, prefix string) {
+		for name, p := range props {
+			prop, _ := p.(map[string]any)
+			field := prefix + name
+			if sub, ok := prop["fields"].(map[string]any); ok {
+				for subName := range sub {
+					multi[field+"."+subName] = field
+				}
+			}
+			if prop["type"] == "search_as_you_type" {
+				for _, subName := range []string{"_2gram", "_3gram", "_index_prefix"} {
+					multi[field+"."+subName] = field
+				}
+			}
+			if nested, ok := prop["properties"].(map[string]any); ok {
+				walk(nested, field+".")
+			}
+		}
+	}
+	props, _ := spec.Mappings["properties"].(map[string]any)
+	walk(props, "")
+	return multi
+}
+
+// withMultiFields 返回补充了多字段取值的文档副本，多字段以带点的键保存
+func withMultiFields(doc map[string]any, multi map[string]string) map[string]any {
+	if len(multi) == 0 {
+		return doc
+	}
+	out := make(map[string]any, len(doc)+len(multi))
+	for k, v := range doc {
+		out[k] = v
+	}
+	for sub, parent := range multi {
+		if values := lookupPath(doc, strings.Split(parent, ".")); len(values) > 0 {
+			out[sub] = values
+		}
+	}
+	return out
+}
+
+func lookupPath(v any, parts []string) []any {
+	if len(parts) == 0 {
+		switch x := v.(type) {
+		case nil:
+			return nil
+		case []any:
+			var out []any
+			for _, e := range x {
+				out = append(out, lookupPath(e, nil)...)
+			}
+			return out
+		default:
+			return []any{x}
+		}
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		// 兼容字段名本身带点的写法
+		for i := len(parts); i > 0; i-- {
+			if child, ok := x[strings.Join(parts[:i], ".")]; ok {
+				return lookupPath(child, parts[i:])
+			}
+		}
+	case []any:
+		var out []any
+		for _, e := range x {
+			out = append(out, lookupPath(e, parts)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// lookupPattern 取字段名匹配通配符的所有顶层字段值
+func lookupPattern(doc map[string]any, pattern string) []any {
+	var out []any
+	for k, v := range doc {
+		if ok, _ := path.Match(pattern, k); ok {
+			out = append(out, lookupPath(v, nil)...)
+		}
+	}
+	return out
+}
+
+func parseSort(raw json.RawMessage) ([]sortField, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var list []any
+	if err := json.Unmarshal(raw, &list); err != nil {
+		var one any
+		if err = json.Unmarshal(raw, &one); err != nil {
+			return nil, err
+		}
+		list = []any{one}
+	}
+	sorts := make([]sortField, 0, len(list))
+	for _, item := range list {
+		switch v := item.(type) {
+		case string:
+			field, order, _ := strings.Cut(v, ":")
+			sorts = append(sorts, sortField{field: field, desc: order == "desc" || order == "" && field == "_score"})
+		case map[string]any:
+			for field, opt := range v {
+				order := opt
+				if m, ok := opt.(map[string]any); ok {
+					order = m["order"]
+				}
+				desc := order == "desc" || order == nil && field == "_score"
+				sorts = append(sorts, sortField{field: field, desc: desc})
+			}
+		default:
+			return nil, fmt.Errorf("malformed sort [%v]", item)
+		}
+	}
+	return sorts, nil
+}
+
+func sortValue(h hit, field string) any {
+	switch field {
+	case "_score":
+		return 1.0
+	case "_id":
+		return h.id
+	case "_doc":
+		return h.doc.seqNo
+	}
+	values := lookup(h.fields, field)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// compareSort 按排序字段比较，缺失值总是排在最后
+func compareSort(a, b []any, sorts []sortField) int {
+	for i, sf := range sorts {
+		if i >= len(a) || i >= len(b) {
+			break
+		}
+		var c int
+		switch {
+		case a[i] == nil && b[i] == nil:
+			continue
+		case a[i] == nil:
+			return 1
+		case b[i] == nil:
+			return -1
+		default:
+			c = compareValues(a[i], b[i])
+		}
+		if sf.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+type sourceFilter struct {
+	disabled bool
+	includes []string
+	excludes []string
+}
+
+func parseSourceFilter(raw json.RawMessage) (sourceFilter, error) {
+	var f sourceFilter
+	if len(raw) == 0 {
+		return f, nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return f, err
+	}
+	toStrings := func(v any) []string {
+		var out []string
+		for _, e := range asList(v) {
+			out = append(out, fmt.Sprint(e))
+		}
+		return out
+	}
+	switch x := v.(type) {
+	case bool:
+		f.disabled = !x
+	case string, []any:
+		f.includes = toStrings(x)
+	case map[string]any:
+		f.includes, f.excludes = toStrings(x["includes"]), toStrings(x["excludes"])
+	}
+	return f, nil
+}
+
+// apply 按顶层字段过滤 _source，includes 中的 a.b 会保留整个 a，excludes 只支持顶层字段
+func (f sourceFilter) apply(doc *document) any {
+	if f.disabled {
+		return nil
+	}
+	if len(f.includes) == 0 && len(f.excludes) == 0 {
+		return doc.source
+	}
+	match := func(patterns []string, key string, nested bool) bool {
+		for _, p := range patterns {
+			top, rest, _ := strings.Cut(p, ".")
+			if rest != "" && !nested {
+				continue
+			}
+			if ok, _ := path.Match(top, key); ok {
+				return true
+			}
+		}
+		return false
+	}
+	out := make(map[string]any, len(doc.fields))
+	for k, v := range doc.fields {
+		if len(f.includes) > 0 && !match(f.includes, k, true) {
+			continue
+		}
+		if match(f.excludes, k, false) {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
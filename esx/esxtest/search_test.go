@@ -0,0 +1,88 @@
+package esxtest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/trancecho/open-sdk/esx"
+	"github.com/trancecho/open-sdk/esx/esxtest"
+)
+
+func TestSearchAfter(t *testing.T) {
+	s := esxtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+	ctx := context.Background()
+	for i := 1; i <= 7; i++ {
+		s.Put("posts", fmt.Sprint(i), post{Title: fmt.Sprintf("post %d", i), Views: i % 3})
+	}
+
+	var (
+		ids   []string
+		after []any
+	)
+	for page := 0; page < 10; page++ {
+		req := esx.NewSearch().Sort("-views", "_id").Size(3)
+		if after != nil {
+			req.SearchAfter(after...)
+		}
+		res, err := c.Search(ctx, "posts", req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != 7 {
+			t.Fatalf("total should ignore search_after, got %d", res.Total)
+		}
+		if len(res.Hits) == 0 {
+			break
+		}
+		for _, h := range res.Hits {
+			ids = append(ids, h.ID)
+		}
+		after = res.Hits[len(res.Hits)-1].Sort
+	}
+	// views: 2 => 2,5；1 => 1,4,7；0 => 3,6
+	if got := fmt.Sprint(ids); got != "[2 5 1 4 7 3 6]" {
+		t.Fatalf("unexpected order %s", got)
+	}
+
+	if _, err := c.Search(ctx, "posts", esx.NewSearch().Sort("views").SearchAfter(1, "x")); err == nil {
+		t.Fatal("search_after with more values than sort fields should fail")
+	}
+}
+
+func TestMultiFields(t *testing.T) {
+	s := esxtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+	ctx := context.Background()
+	spec := esx.IndexSpec{Mappings: map[string]any{"properties": map[string]any{
+		"title": map[string]any{"type": "text", "fields": map[string]any{"raw": map[string]any{"type": "keyword"}}},
+	}}}
+	if _, err := c.CreateIndex(ctx, "posts", spec); err != nil {
+		t.Fatal(err)
+	}
+	s.Put("posts", "1", map[string]any{"title": "Hello", "author": map[string]any{"name": "ann"}})
+
+	cases := []struct {
+		query esx.Query
+		want  int64
+	}{
+		{esx.Term("title.raw", "Hello"), 1},
+		{esx.Term("title.keyword", "Hello"), 1},
+		{esx.Term("author.name", "ann"), 1},
+		// 未映射的子字段不能回退到父字段
+		{esx.Term("author.name.other", "ann"), 0},
+		{esx.Term("title.other", "Hello"), 0},
+	}
+	for _, tc := range cases {
+		res, err := c.Search(ctx, "posts", esx.NewSearch().Query(tc.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != tc.want {
+			t.Errorf("%v: expected %d hits, got %d", tc.query.Source(), tc.want, res.Total)
+		}
+	}
+}
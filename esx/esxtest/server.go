@@ -0,0 +1,867 @@
+// Package esxtest 提供进程内的 Elasticsearch 替身，用于在没有集群的环境中测试依赖 esx 的代码：
+//
+//	fake := esxtest.NewServer()
+//	defer fake.Close()
+//	client, _ := esx.NewClient(esx.WithAddress([]string{fake.URL}))
+//
+// 只模拟 esx 用到的 REST API 子集，数据保存在内存中，写入立即可见（无需 refresh）。
+// 搜索支持常用查询、排序、分页、search_after、_source 过滤和 completion 建议，不计算相关度，不支持高亮和聚合
+package esxtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/trancecho/open-sdk/esx"
+)
+
+// Server 内存中的 Elasticsearch 替身
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	indices   map[string]*index
+	aliases   map[string]map[string]bool // 别名 => 索引 => 是否为写索引
+	templates map[string]json.RawMessage
+	tasks     map[string]map[string]any
+	seqNo     int64
+	autoID    int64
+}
+
+type index struct {
+	body json.RawMessage // 创建索引时的 settings、mappings
+	docs map[string]*document
+}
+
+type document struct {
+	source  json.RawMessage
+	fields  map[string]any
+	version int64
+	seqNo   int64
+}
+
+// NewServer 启动替身服务，使用完毕后调用 Close
+func NewServer() *Server {
+	s := &Server{}
+	s.Reset()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client 创建连接到替身的 esx 客户端
+func (s *Server) Client(opts ...esx.Option) *esx.Client {
+	c, err := esx.NewClient(append([]esx.Option{esx.WithAddress([]string{s.URL})}, opts...)...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Reset 清空所有索引、别名、模板和任务
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indices = make(map[string]*index)
+	s.aliases = make(map[string]map[string]bool)
+	s.templates = make(map[string]json.RawMessage)
+	s.tasks = make(map[string]map[string]any)
+}
+
+// Put 直接写入文档，索引不存在时自动创建，用于准备测试数据
+func (s *Server) Put(indexName, id string, doc any) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status, res := s.indexDoc(indexName, id, data, noParams()); status >= 300 {
+		panic(fmt.Sprintf("esxtest: put %s/%s: %v", indexName, id, res))
+	}
+}
+
+// Documents 返回索引（或别名）中的所有文档原文
+func (s *Server) Documents(name string) map[string]json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := make(map[string]json.RawMessage)
+	for _, idx := range s.resolve(name) {
+		for id, doc := range s.indices[idx].docs {
+			docs[id] = doc.source
+		}
+	}
+	return docs
+}
+
+// Indices 返回所有索引名
+func (s *Server) Indices() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.indices))
+	for name := range s.indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Aliases 返回别名指向的索引
+func (s *Server) Aliases(alias string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.aliases[alias]))
+	for name := range s.aliases[alias] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Mapping 返回创建索引时提交的 settings、mappings 原文
+func (s *Server) Mapping(indexName string) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if idx, ok := s.indices[indexName]; ok {
+		return idx.body
+	}
+	return nil
+}
+
+// Template 返回索引模板原文
+func (s *Server) Template(name string) json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.templates[name]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		status, res := errorBody(400, "parse_exception", err.Error())
+		writeJSON(w, r, status, res)
+		return
+	}
+	s.mu.Lock()
+	status, res := s.route(r, body)
+	s.mu.Unlock()
+	writeJSON(w, r, status, res)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, res any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead && res != nil {
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+func errorBody(status int, typ, reason string) (int, any) {
+	return status, map[string]any{
+		"error":  map[string]any{"type": typ, "reason": reason, "root_cause": []any{map[string]any{"type": typ, "reason": reason}}},
+		"status": status,
+	}
+}
+
+func unsupported(r *http.Request) (int, any) {
+	return errorBody(400, "illegal_argument_exception", fmt.Sprintf("esxtest: unsupported request %s %s", r.Method, r.URL.Path))
+}
+
+func (s *Server) route(r *http.Request, body []byte) (int, any) {
+	p := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+	if p[0] == "" {
+		return 200, map[string]any{
+			"name":         "esxtest",
+			"cluster_name": "esxtest",
+			"version":      map[string]any{"number": "8.17.0", "build_flavor": "default"},
+			"tagline":      "You Know, for Search",
+		}
+	}
+	switch p[0] {
+	case "_bulk":
+		return s.bulk("", body)
+	case "_search":
+		return s.search("_all", body)
+	case "_count":
+		return s.count("_all", body)
+	case "_refresh":
+		return 200, shards()
+	case "_aliases":
+		if r.Method == http.MethodPost {
+			return s.updateAliases(body)
+		}
+	case "_alias":
+		if r.Method == http.MethodGet && len(p) == 2 {
+			return s.getAlias(p[1])
+		}
+	case "_cat":
+		if len(p) >= 2 && p[1] == "indices" {
+			pattern := "*"
+			if len(p) == 3 {
+				pattern = p[2]
+			}
+			return s.catIndices(pattern)
+		}
+	case "_reindex":
+		if r.Method == http.MethodPost {
+			return s.reindex(body, q.Get("wait_for_completion") != "false")
+		}
+	case "_tasks":
+		if r.Method == http.MethodGet && len(p) == 2 {
+			task, ok := s.tasks[p[1]]
+			if !ok {
+				return errorBody(404, "resource_not_found_exception", "task ["+p[1]+"] isn't running and hasn't stored its results")
+			}
+			return 200, task
+		}
+	case "_index_template":
+		if len(p) == 2 {
+			return s.indexTemplate(r.Method, p[1], body)
+		}
+	default:
+		return s.routeIndex(r, p, body)
+	}
+	return unsupported(r)
+}
+
+func (s *Server) routeIndex(r *http.Request, p []string, body []byte) (int, any) {
+	name, q := p[0], r.URL.Query()
+	if len(p) == 1 {
+		switch r.Method {
+		case http.MethodPut:
+			return s.createIndex(name, body)
+		case http.MethodHead:
+			if len(s.resolve(name)) == 0 {
+				return 404, nil
+			}
+			return 200, nil
+		case http.MethodGet:
+			return s.getIndex(name)
+		case http.MethodDelete:
+			return s.deleteIndex(name)
+		}
+		return unsupported(r)
+	}
+	switch op := p[1]; {
+	case op == "_doc" && len(p) == 2 && r.Method == http.MethodPost:
+		s.autoID++
+		return s.indexDoc(name, "esxtest-"+strconv.FormatInt(s.autoID, 10), body, q)
+	case op == "_doc" && len(p) == 3:
+		switch r.Method {
+		case http.MethodPut, http.MethodPost:
+			return s.indexDoc(name, p[2], body, q)
+		case http.MethodGet, http.MethodHead:
+			return s.getDoc(name, p[2])
+		case http.MethodDelete:
+			return s.deleteDoc(name, p[2])
+		}
+	case op == "_create" && len(p) == 3 && (r.Method == http.MethodPut || r.Method == http.MethodPost):
+		q.Set("op_type", "create")
+		return s.indexDoc(name, p[2], body, q)
+	case op == "_update" && len(p) == 3 && r.Method == http.MethodPost:
+		return s.updateDoc(name, p[2], body)
+	case op == "_search":
+		return s.search(name, body)
+	case op == "_count":
+		return s.count(name, body)
+	case op == "_bulk":
+		return s.bulk(name, body)
+	case op == "_refresh":
+		if len(s.resolve(name)) == 0 {
+			return indexNotFound(name)
+		}
+		return 200, shards()
+	}
+	return unsupported(r)
+}
+
+func shards() map[string]any {
+	return map[string]any{"_shards": map[string]any{"total": 1, "successful": 1, "failed": 0}}
+}
+
+func indexNotFound(name string) (int, any) {
+	return errorBody(404, "index_not_found_exception", "no such index ["+name+"]")
+}
+
+func noParams() map[string][]string {
+	return map[string][]string{}
+}
+
+// resolve 把索引名、别名、逗号分隔列表或通配符解析为已存在的索引
+func (s *Server) resolve(name string) []string {
+	set := make(map[string]bool)
+	for _, part := range strings.Split(name, ",") {
+		if part == "_all" {
+			part = "*"
+		}
+		if strings.Contains(part, "*") {
+			for idx := range s.indices {
+				if ok, _ := path.Match(part, idx); ok {
+					set[idx] = true
+				}
+			}
+			for alias, targets := range s.aliases {
+				if ok, _ := path.Match(part, alias); ok {
+					for idx := range targets {
+						set[idx] = true
+					}
+				}
+			}
+			continue
+		}
+		if _, ok := s.indices[part]; ok {
+			set[part] = true
+		}
+		for idx := range s.aliases[part] {
+			set[idx] = true
+		}
+	}
+	names := make([]string, 0, len(set))
+	for idx := range set {
+		names = append(names, idx)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeIndex 返回写入目标索引，别名需唯一指向一个索引或有写索引；索引不存在时自动创建
+func (s *Server) writeIndex(name string) (string, error) {
+	if targets, ok := s.aliases[name]; ok {
+		for idx, write := range targets {
+			if write || len(targets) == 1 {
+				return idx, nil
+			}
+		}
+		return "", fmt.Errorf("no write index is defined for alias [%s]", name)
+	}
+	if _, ok := s.indices[name]; !ok {
+		s.indices[name] = &index{docs: make(map[string]*document)}
+	}
+	return name, nil
+}
+
+// lookup 返回读取文档时使用的索引，别名需唯一指向一个索引
+func (s *Server) lookup(name string) (*index, string, bool) {
+	names := s.resolve(name)
+	if len(names) != 1 {
+		return nil, "", false
+	}
+	return s.indices[names[0]], names[0], true
+}
+
+func (s *Server) createIndex(name string, body []byte) (int, any) {
+	if _, ok := s.indices[name]; ok {
+		return errorBody(400, "resource_already_exists_exception", "index ["+name+"] already exists")
+	}
+	if _, ok := s.aliases[name]; ok {
+		return errorBody(400, "invalid_index_name_exception", "Invalid index name ["+name+"], already exists as alias")
+	}
+	idx := &index{docs: make(map[string]*document)}
+	if len(bytes.TrimSpace(body)) > 0 {
+		var spec struct {
+			Aliases map[string]struct {
+				IsWriteIndex bool `json:"is_write_index"`
+			} `json:"aliases"`
+		}
+		if err := json.Unmarshal(body, &spec); err != nil {
+			return errorBody(400, "parse_exception", err.Error())
+		}
+		for alias, a := range spec.Aliases {
+			s.addAlias(alias, name, a.IsWriteIndex)
+		}
+		idx.body = append(json.RawMessage(nil), body...)
+	}
+	s.indices[name] = idx
+	return 200, map[string]any{"acknowledged": true, "shards_acknowledged": true, "index": name}
+}
+
+func (s *Server) getIndex(name string) (int, any) {
+	names := s.resolve(name)
+	if len(names) == 0 {
+		return indexNotFound(name)
+	}
+	res := make(map[string]any, len(names))
+	for _, n := range names {
+		info := map[string]any{"aliases": s.aliasesOf(n), "mappings": map[string]any{}, "settings": map[string]any{}}
+		if body := s.indices[n].body; body != nil {
+			var spec map[string]any
+			_ = json.Unmarshal(body, &spec)
+			for _, k := range []string{"mappings", "settings"} {
+				if v, ok := spec[k]; ok {
+					info[k] = v
+				}
+			}
+		}
+		res[n] = info
+	}
+	return 200, res
+}
+
+func (s *Server) deleteIndex(name string) (int, any) {
+	names := s.resolve(name)
+	if len(names) == 0 {
+		return indexNotFound(name)
+	}
+	for _, n := range names {
+		delete(s.indices, n)
+		for alias, targets := range s.aliases {
+			delete(targets, n)
+			if len(targets) == 0 {
+				delete(s.aliases, alias)
+			}
+		}
+	}
+	return 200, map[string]any{"acknowledged": true}
+}
+
+func (s *Server) indexTemplate(method, name string, body []byte) (int, any) {
+	switch method {
+	case http.MethodPut, http.MethodPost:
+		if !json.Valid(body) {
+			return errorBody(400, "parse_exception", "request body is required")
+		}
+		s.templates[name] = append(json.RawMessage(nil), body...)
+		return 200, map[string]any{"acknowledged": true}
+	case http.MethodGet:
+		tpl, ok := s.templates[name]
+		if !ok {
+			return errorBody(404, "resource_not_found_exception", "index template matching ["+name+"] not found")
+		}
+		return 200, map[string]any{"index_templates": []any{map[string]any{"name": name, "index_template": tpl}}}
+	case http.MethodDelete:
+		if _, ok := s.templates[name]; !ok {
+			return errorBody(404, "resource_not_found_exception", "index_template ["+name+"] missing")
+		}
+		delete(s.templates, name)
+		return 200, map[string]any{"acknowledged": true}
+	}
+	return errorBody(405, "method_not_allowed", method+" is not allowed")
+}
+
+// docResult 单个文档操作的响应
+func docResult(indexName, id string, doc *document, result string) map[string]any {
+	res := map[string]any{"_index": indexName, "_id": id, "result": result, "_shards": shards()["_shards"]}
+	if doc != nil {
+		res["_version"], res["_seq_no"], res["_primary_term"] = doc.version, doc.seqNo, 1
+	}
+	return res
+}
+
+func (s *Server) newDocument(source []byte, version int64) (*document, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(source, &fields); err != nil {
+		return nil, err
+	}
+	s.seqNo++
+	return &document{source: append(json.RawMessage(nil), source...), fields: fields, version: version, seqNo: s.seqNo}, nil
+}
+
+func (s *Server) indexDoc(name, id string, body []byte, q map[string][]string) (int, any) {
+	indexName, err := s.writeIndex(name)
+	if err != nil {
+		return errorBody(400, "illegal_argument_exception", err.Error())
+	}
+	idx := s.indices[indexName]
+	old := idx.docs[id]
+	if get(q, "op_type") == "create" && old != nil {
+		return errorBody(409, "version_conflict_engine_exception", fmt.Sprintf("[%s]: version conflict, document already exists", id))
+	}
+	version := int64(1)
+	if old != nil {
+		version = old.version + 1
+	}
+	if v := get(q, "version"); v != "" && strings.HasPrefix(get(q, "version_type"), "external") {
+		if version, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return errorBody(400, "illegal_argument_exception", "invalid version ["+v+"]")
+		}
+		if old != nil && (version < old.version || version == old.version && get(q, "version_type") != "external_gte") {
+			return errorBody(409, "version_conflict_engine_exception",
+				fmt.Sprintf("[%s]: version conflict, current version [%d] is higher or equal to the one provided [%d]", id, old.version, version))
+		}
+	}
+	doc, err := s.newDocument(body, version)
+	if err != nil {
+		return errorBody(400, "mapper_parsing_exception", "failed to parse: "+err.Error())
+	}
+	idx.docs[id] = doc
+	if old != nil {
+		return 200, docResult(indexName, id, doc, "updated")
+	}
+	return 201, docResult(indexName, id, doc, "created")
+}
+
+func get(q map[string][]string, key string) string {
+	if v := q[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (s *Server) getDoc(name, id string) (int, any) {
+	idx, indexName, ok := s.lookup(name)
+	if !ok {
+		return indexNotFound(name)
+	}
+	doc, found := idx.docs[id]
+	if !found {
+		return 404, map[string]any{"_index": indexName, "_id": id, "found": false}
+	}
+	return 200, map[string]any{
+		"_index": indexName, "_id": id, "found": true, "_source": doc.source,
+		"_version": doc.version, "_seq_no": doc.seqNo, "_primary_term": 1,
+	}
+}
+
+func (s *Server) deleteDoc(name, id string) (int, any) {
+	indexName, err := s.writeIndex(name)
+	if err != nil {
+		return errorBody(400, "illegal_argument_exception", err.Error())
+	}
+	idx := s.indices[indexName]
+	doc, found := idx.docs[id]
+	if !found {
+		return 404, docResult(indexName, id, nil, "not_found")
+	}
+	delete(idx.docs, id)
+	s.seqNo++
+	return 200, docResult(indexName, id, &document{version: doc.version + 1, seqNo: s.seqNo}, "deleted")
+}
+
+func (s *Server) updateDoc(name, id string, body []byte) (int, any) {
+	var req struct {
+		Doc         map[string]any `json:"doc"`
+		DocAsUpsert bool           `json:"doc_as_upsert"`
+		Upsert      map[string]any `json:"upsert"`
+		Script      any            `json:"script"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return errorBody(400, "x_content_parse_exception", err.Error())
+	}
+	if req.Script != nil {
+		return errorBody(400, "illegal_argument_exception", "esxtest: scripted updates are not supported")
+	}
+	indexName, err := s.writeIndex(name)
+	if err != nil {
+		return errorBody(400, "illegal_argument_exception", err.Error())
+	}
+	idx := s.indices[indexName]
+	old, found := idx.docs[id]
+	var fields map[string]any
+	switch {
+	case found:
+		fields = merge(cloneMap(old.fields), req.Doc)
+	case req.DocAsUpsert:
+		fields = req.Doc
+	case req.Upsert != nil:
+		fields = req.Upsert
+	default:
+		return errorBody(404, "document_missing_exception", fmt.Sprintf("[%s]: document missing", id))
+	}
+	source, err := json.Marshal(fields)
+	if err != nil {
+		return errorBody(400, "illegal_argument_exception", err.Error())
+	}
+	if found && bytes.Equal(source, mustMarshal(old.fields)) {
+		return 200, docResult(indexName, id, old, "noop")
+	}
+	version := int64(1)
+	if found {
+		version = old.version + 1
+	}
+	doc, err := s.newDocument(source, version)
+	if err != nil {
+		return errorBody(400, "mapper_parsing_exception", err.Error())
+	}
+	idx.docs[id] = doc
+	if found {
+		return 200, docResult(indexName, id, doc, "updated")
+	}
+	return 201, docResult(indexName, id, doc, "created")
+}
+
+func mustMarshal(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func cloneMap(m map[string]any) map[string]any {
+	var c map[string]any
+	_ = json.Unmarshal(mustMarshal(m), &c)
+	return c
+}
+
+// merge 把 patch 递归合并到 dst，与 ES 部分更新的规则一致
+func merge(dst, patch map[string]any) map[string]any {
+	if dst == nil {
+		dst = make(map[string]any)
+	}
+	for k, v := range patch {
+		if pm, ok := v.(map[string]any); ok {
+			if dm, ok := dst[k].(map[string]any); ok {
+				dst[k] = merge(dm, pm)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+func (s *Server) bulk(defaultIndex string, body []byte) (int, any) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var (
+		items  []any
+		errors bool
+	)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var action map[string]struct {
+			Index       string `json:"_index"`
+			ID          string `json:"_id"`
+			Version     *int64 `json:"version"`
+			VersionType string `json:"version_type"`
+		}
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			return errorBody(400, "illegal_argument_exception", "Malformed action/metadata line ["+string(line)+"]")
+		}
+		for op, meta := range action {
+			indexName := meta.Index
+			if indexName == "" {
+				indexName = defaultIndex
+			}
+			var source []byte
+			if op != "delete" {
+				if !scanner.Scan() {
+					return errorBody(400, "illegal_argument_exception", "The bulk request must be terminated by a newline [\\n]")
+				}
+				source = append([]byte(nil), scanner.Bytes()...)
+			}
+			q := noParams()
+			if meta.Version != nil {
+				q["version"] = []string{strconv.FormatInt(*meta.Version, 10)}
+				q["version_type"] = []string{meta.VersionType}
+			}
+			var (
+				status int
+				res    any
+			)
+			switch {
+			case indexName == "":
+				status, res = errorBody(400, "action_request_validation_exception", "Validation Failed: 1: index is missing;")
+			case meta.ID == "" && op != "index":
+				// 只有 index 可以由集群生成 id
+				status, res = errorBody(400, "action_request_validation_exception", "Validation Failed: 1: id is missing;")
+			case op == "index":
+				id := meta.ID
+				if id == "" {
+					s.autoID++
+					id = "esxtest-" + strconv.FormatInt(s.autoID, 10)
+				}
+				status, res = s.indexDoc(indexName, id, source, q)
+			case op == "create":
+				q["op_type"] = []string{"create"}
+				status, res = s.indexDoc(indexName, meta.ID, source, q)
+			case op == "update":
+				status, res = s.updateDoc(indexName, meta.ID, source)
+			case op == "delete":
+				status, res = s.deleteDoc(indexName, meta.ID)
+			default:
+				return errorBody(400, "illegal_argument_exception", "Malformed action/metadata line, expected one of [create, delete, index, update] but found ["+op+"]")
+			}
+			item, _ := res.(map[string]any)
+			if errBody, ok := item["error"]; ok {
+				item = map[string]any{"_index": indexName, "_id": meta.ID, "error": errBody}
+			}
+			if item == nil {
+				item = map[string]any{}
+			}
+			item["status"] = status
+			if status >= 300 {
+				errors = true
+			}
+			items = append(items, map[string]any{op: item})
+		}
+	}
+	return 200, map[string]any{"took": 1, "errors": errors, "items": items}
+}
+
+func (s *Server) addAlias(alias, indexName string, write bool) {
+	if s.aliases[alias] == nil {
+		s.aliases[alias] = make(map[string]bool)
+	}
+	if write {
+		for idx := range s.aliases[alias] {
+			s.aliases[alias][idx] = false
+		}
+	}
+	s.aliases[alias][indexName] = write
+}
+
+func (s *Server) aliasesOf(indexName string) map[string]any {
+	res := make(map[string]any)
+	for alias, targets := range s.aliases {
+		if write, ok := targets[indexName]; ok {
+			if write {
+				res[alias] = map[string]any{"is_write_index": true}
+			} else {
+				res[alias] = map[string]any{}
+			}
+		}
+	}
+	return res
+}
+
+func (s *Server) getAlias(name string) (int, any) {
+	res := make(map[string]any)
+	for alias, targets := range s.aliases {
+		if ok, _ := path.Match(name, alias); !ok {
+			continue
+		}
+		for idx, write := range targets {
+			entry, _ := res[idx].(map[string]any)
+			if entry == nil {
+				entry = map[string]any{"aliases": map[string]any{}}
+				res[idx] = entry
+			}
+			detail := map[string]any{}
+			if write {
+				detail["is_write_index"] = true
+			}
+			entry["aliases"].(map[string]any)[alias] = detail
+		}
+	}
+	if len(res) == 0 {
+		return errorBody(404, "aliases_not_found_exception", "alias ["+name+"] missing")
+	}
+	return 200, res
+}
+
+// updateAliases 先校验全部操作再统一执行，保证切换是原子的
+func (s *Server) updateAliases(body []byte) (int, any) {
+	var req struct {
+		Actions []map[string]struct {
+			Index        string `json:"index"`
+			Alias        string `json:"alias"`
+			IsWriteIndex bool   `json:"is_write_index"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return errorBody(400, "x_content_parse_exception", err.Error())
+	}
+	for _, action := range req.Actions {
+		for op, a := range action {
+			if _, ok := s.indices[a.Index]; !ok {
+				return indexNotFound(a.Index)
+			}
+			if _, ok := s.aliases[a.Alias][a.Index]; op == "remove" && !ok {
+				return errorBody(404, "aliases_not_found_exception", "aliases ["+a.Alias+"] missing")
+			}
+			if op != "add" && op != "remove" && op != "remove_index" {
+				return errorBody(400, "illegal_argument_exception", "esxtest: unsupported alias action ["+op+"]")
+			}
+		}
+	}
+	for _, action := range req.Actions {
+		for op, a := range action {
+			switch op {
+			case "add":
+				s.addAlias(a.Alias, a.Index, a.IsWriteIndex)
+			case "remove":
+				delete(s.aliases[a.Alias], a.Index)
+				if len(s.aliases[a.Alias]) == 0 {
+					delete(s.aliases, a.Alias)
+				}
+			case "remove_index":
+				_, _ = s.deleteIndex(a.Index)
+			}
+		}
+	}
+	return 200, map[string]any{"acknowledged": true}
+}
+
+func (s *Server) catIndices(pattern string) (int, any) {
+	names := s.resolve(pattern)
+	if len(names) == 0 && !strings.Contains(pattern, "*") {
+		return indexNotFound(pattern)
+	}
+	rows := make([]map[string]any, 0, len(names))
+	for _, n := range names {
+		rows = append(rows, map[string]any{
+			"health": "green", "status": "open", "index": n,
+			"docs.count": strconv.Itoa(len(s.indices[n].docs)),
+		})
+	}
+	return 200, rows
+}
+
+// reindex 同步复制文档，wait_for_completion=false 时返回一个已完成的任务
+func (s *Server) reindex(body []byte, wait bool) (int, any) {
+	var req struct {
+		Source struct {
+			Index json.RawMessage `json:"index"`
+		} `json:"source"`
+		Dest struct {
+			Index string `json:"index"`
+		} `json:"dest"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return errorBody(400, "x_content_parse_exception", err.Error())
+	}
+	var sources []string
+	if json.Unmarshal(req.Source.Index, &sources) != nil {
+		var one string
+		_ = json.Unmarshal(req.Source.Index, &one)
+		sources = []string{one}
+	}
+	if req.Dest.Index == "" || len(sources) == 0 {
+		return errorBody(400, "action_request_validation_exception", "Validation Failed: source and dest index are required")
+	}
+	var docs []struct {
+		id  string
+		doc *document
+	}
+	for _, src := range sources {
+		names := s.resolve(src)
+		if len(names) == 0 {
+			return indexNotFound(src)
+		}
+		for _, n := range names {
+			for id, doc := range s.indices[n].docs {
+				docs = append(docs, struct {
+					id  string
+					doc *document
+				}{id, doc})
+			}
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].doc.seqNo < docs[j].doc.seqNo })
+	created := 0
+	for _, d := range docs {
+		if status, res := s.indexDoc(req.Dest.Index, d.id, d.doc.source, noParams()); status >= 300 {
+			return status, res
+		}
+		created++
+	}
+	result := map[string]any{"took": 1, "timed_out": false, "total": len(docs), "created": created, "updated": 0, "failures": []any{}}
+	if wait {
+		return 200, result
+	}
+	taskID := fmt.Sprintf("esxtest:%d", len(s.tasks)+1)
+	s.tasks[taskID] = map[string]any{"completed": true, "task": map[string]any{"action": "indices:data/write/reindex"}, "response": result}
+	return 200, map[string]any{"task": taskID}
+}
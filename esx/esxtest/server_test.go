@@ -0,0 +1,192 @@
+package esxtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/trancecho/open-sdk/esx"
+	"github.com/trancecho/open-sdk/esx/esxtest"
+)
+
+type post struct {
+	Title string `json:"title"`
+	Views int    `json:"views"`
+}
+
+func TestBulkIndexer(t *testing.T) {
+	s := esxtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+	ctx := context.Background()
+	s.Put("posts", "3", post{Title: "old"})
+
+	var (
+		mu     sync.Mutex
+		failed []string
+	)
+	onFailure := func(_ context.Context, item esx.BulkItem, _ esx.BulkItemResponse, _ error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, item.Action+":"+item.ID)
+	}
+	b := c.NewBulkIndexer(esx.WithBulkIndex("posts"), esx.WithFlushInterval(0), esx.WithBulkRetries(0, 0))
+	items := []esx.BulkItem{
+		{ID: "1", Doc: post{Title: "first", Views: 1}},
+		{ID: "2", Doc: post{Title: "second"}, Version: 5},
+		{ID: "2", Doc: post{Title: "stale"}, Version: 4, OnFailure: onFailure},
+		{Action: esx.BulkUpdate, ID: "1", Doc: map[string]any{"views": 2}},
+		{Action: esx.BulkCreate, ID: "1", Doc: post{Title: "dup"}, OnFailure: onFailure},
+		{Action: esx.BulkDelete, ID: "3"},
+	}
+	for _, item := range items {
+		if err := b.Add(ctx, item); err != nil {
+			t.Fatalf("add %s %s: %v", item.Action, item.ID, err)
+		}
+	}
+	if err := b.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	stats := b.Stats()
+	if stats.Added != 6 || stats.Succeeded != 4 || stats.Failed != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if strings.Join(failed, ",") != "index:2,create:1" {
+		t.Fatalf("unexpected failures %v", failed)
+	}
+	docs := s.Documents("posts")
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(docs))
+	}
+	var first, second post
+	_ = json.Unmarshal(docs["1"], &first)
+	_ = json.Unmarshal(docs["2"], &second)
+	if first != (post{Title: "first", Views: 2}) || second.Title != "second" {
+		t.Fatalf("unexpected documents %s %s", docs["1"], docs["2"])
+	}
+}
+
+func TestBulkRequiresID(t *testing.T) {
+	s := esxtest.NewServer()
+	defer s.Close()
+	s.Put("posts", "1", post{Title: "first"})
+
+	body := `{"delete":{"_index":"posts"}}
+{"create":{"_index":"posts"}}
+{"title":"x"}
+{"update":{"_index":"posts"}}
+{"doc":{"title":"x"}}
+{"index":{"_index":"posts"}}
+{"title":"auto"}
+`
+	res, err := http.Post(s.URL+"/_bulk", "application/x-ndjson", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var result struct {
+		Errors bool                              `json:"errors"`
+		Items  []map[string]esx.BulkItemResponse `json:"items"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Errors || len(result.Items) != 4 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for i, op := range []string{"delete", "create", "update"} {
+		item := result.Items[i][op]
+		if item.Status != 400 || item.Error == nil || item.Error.Type != "action_request_validation_exception" {
+			t.Fatalf("%s without id: %+v", op, item)
+		}
+	}
+	if item := result.Items[3]["index"]; item.Status != 201 || item.ID == "" {
+		t.Fatalf("index without id should generate one: %+v", item)
+	}
+	if len(s.Documents("posts")) != 2 {
+		t.Fatalf("invalid actions must not change documents")
+	}
+}
+
+func TestReindexSwapsAlias(t *testing.T) {
+	s := esxtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+	ctx := context.Background()
+
+	index, err := c.EnsureAlias(ctx, "posts", esx.SpecFor[post](nil))
+	if err != nil || index != "posts_v1" {
+		t.Fatalf("ensure alias: %s %v", index, err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if _, err = c.IndexDocument(ctx, "posts", id, post{Title: "post " + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spec := esx.SpecFor[post](nil).SetField("title", map[string]any{"type": "keyword"})
+	index, err = c.Reindex(ctx, "posts", spec, esx.WithReindexPollInterval(time.Millisecond), esx.WithDeleteOld())
+	if err != nil || index != "posts_v2" {
+		t.Fatalf("reindex: %s %v", index, err)
+	}
+	if aliases := s.Aliases("posts"); len(aliases) != 1 || aliases[0] != "posts_v2" {
+		t.Fatalf("alias should point to posts_v2 only, got %v", aliases)
+	}
+	if indices := s.Indices(); len(indices) != 1 || indices[0] != "posts_v2" {
+		t.Fatalf("old index should be deleted, got %v", indices)
+	}
+	if docs := s.Documents("posts_v2"); len(docs) != 2 {
+		t.Fatalf("expected 2 documents copied, got %d", len(docs))
+	}
+	if !strings.Contains(string(s.Mapping("posts_v2")), `"keyword"`) {
+		t.Fatalf("new index should use the new mapping: %s", s.Mapping("posts_v2"))
+	}
+
+	// 已有同名的具体索引时不能创建别名
+	if _, err = c.CreateIndex(ctx, "users", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.EnsureAlias(ctx, "users", esx.IndexSpec{}); !errors.Is(err, esx.ErrAliasConflict) {
+		t.Fatalf("expected ErrAliasConflict, got %v", err)
+	}
+}
+
+func TestEnsureAliasLatestIndex(t *testing.T) {
+	s := esxtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+	ctx := context.Background()
+	for _, index := range []string{"posts_v9", "posts_v10"} {
+		if _, err := c.CreateIndex(ctx, index, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updateAliases := func(actions string) {
+		t.Helper()
+		res, err := http.Post(s.URL+"/_aliases", "application/json", strings.NewReader(`{"actions":[`+actions+`]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("update aliases: status %d", res.StatusCode)
+		}
+	}
+
+	// 未标记写索引时按版本号数字比较
+	updateAliases(`{"add":{"index":"posts_v9","alias":"posts"}},{"add":{"index":"posts_v10","alias":"posts"}}`)
+	if index, err := c.EnsureAlias(ctx, "posts", esx.IndexSpec{}); err != nil || index != "posts_v10" {
+		t.Fatalf("expected posts_v10, got %s %v", index, err)
+	}
+	// 显式的写索引优先
+	updateAliases(`{"add":{"index":"posts_v9","alias":"posts","is_write_index":true}}`)
+	if index, err := c.EnsureAlias(ctx, "posts", esx.IndexSpec{}); err != nil || index != "posts_v9" {
+		t.Fatalf("expected write index posts_v9, got %s %v", index, err)
+	}
+}
@@ -0,0 +1,244 @@
+package esxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type completionEntry struct {
+	inputs   []string
+	weight   float64
+	contexts map[string][]string
+}
+
+type suggestOption struct {
+	text   string
+	index  string
+	id     string
+	score  float64
+	seqNo  int64
+	source json.RawMessage
+}
+
+// suggest 执行 completion 建议，输入按小写前缀匹配，fuzzy 按编辑距离近似
+func (s *Server) suggest(name string, req map[string]any) (map[string]any, error) {
+	res := make(map[string]any, len(req))
+	for key, raw := range req {
+		if key == "text" {
+			continue
+		}
+		body, _ := raw.(map[string]any)
+		completion, ok := body["completion"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("esxtest: only completion suggesters are supported, got [%s]", key)
+		}
+		prefix := fmt.Sprint(body["prefix"])
+		if body["prefix"] == nil {
+			prefix = fmt.Sprint(body["text"])
+		}
+		options := s.complete(name, prefix, completion)
+		list := make([]any, 0, len(options))
+		for _, o := range options {
+			list = append(list, map[string]any{"text": o.text, "_index": o.index, "_id": o.id, "_score": o.score, "_source": o.source})
+		}
+		res[key] = []any{map[string]any{"text": prefix, "offset": 0, "length": utf8.RuneCountInString(prefix), "options": list}}
+	}
+	return res, nil
+}
+
+func (s *Server) complete(name, prefix string, req map[string]any) []suggestOption {
+	field := fmt.Sprint(req["field"])
+	size := 5
+	if v, ok := toFloat(req["size"]); ok {
+		size = int(v)
+	}
+	distance := -1
+	if fuzzy, ok := req["fuzzy"].(map[string]any); ok {
+		distance = fuzziness(fuzzy["fuzziness"], prefix)
+	}
+	wanted := make(map[string][]string)
+	if contexts, ok := req["contexts"].(map[string]any); ok {
+		for ctx, values := range contexts {
+			for _, v := range asList(values) {
+				if m, ok := v.(map[string]any); ok {
+					v = m["context"]
+				}
+				wanted[ctx] = append(wanted[ctx], fmt.Sprint(v))
+			}
+		}
+	}
+	prefix = strings.ToLower(prefix)
+
+	var options []suggestOption
+	for _, n := range s.resolve(name) {
+		paths := contextPaths(s.indices[n].body, field)
+		for id, doc := range s.indices[n].docs {
+			best := suggestOption{score: -1}
+			for _, entry := range completionEntries(doc.fields, field, paths) {
+				if !contextMatch(entry.contexts, wanted) {
+					continue
+				}
+				for _, input := range entry.inputs {
+					if prefixMatch(strings.ToLower(input), prefix, distance) && entry.weight > best.score {
+						best = suggestOption{text: input, index: n, id: id, score: entry.weight, seqNo: doc.seqNo, source: doc.source}
+						break
+					}
+				}
+			}
+			if best.score >= 0 {
+				options = append(options, best)
+			}
+		}
+	}
+	sort.Slice(options, func(i, j int) bool {
+		if options[i].score != options[j].score {
+			return options[i].score > options[j].score
+		}
+		return options[i].seqNo < options[j].seqNo
+	})
+	if req["skip_duplicates"] == true {
+		seen := make(map[string]bool)
+		unique := options[:0]
+		for _, o := range options {
+			if !seen[o.text] {
+				seen[o.text] = true
+				unique = append(unique, o)
+			}
+		}
+		options = unique
+	}
+	return options[:min(size, len(options))]
+}
+
+// contextPaths 从索引映射中读取 completion 字段的上下文取值路径
+func contextPaths(body json.RawMessage, field string) map[string]string {
+	var spec struct {
+		Mappings map[string]any `json:"mappings"`
+	}
+	if json.Unmarshal(body, &spec) != nil {
+		return nil
+	}
+	var prop any = spec.Mappings
+	for _, part := range strings.Split(field, ".") {
+		m, _ := prop.(map[string]any)
+		props, _ := m["properties"].(map[string]any)
+		prop = props[part]
+	}
+	m, _ := prop.(map[string]any)
+	paths := make(map[string]string)
+	for _, c := range asList(m["contexts"]) {
+		if cm, ok := c.(map[string]any); ok && cm["path"] != nil {
+			paths[fmt.Sprint(cm["name"])] = fmt.Sprint(cm["path"])
+		}
+	}
+	return paths
+}
+
+// completionEntries 解析 completion 字段的值：字符串、字符串数组或 {input, weight, contexts} 对象
+func completionEntries(doc map[string]any, field string, paths map[string]string) []completionEntry {
+	fromPath := make(map[string][]string)
+	for ctx, p := range paths {
+		for _, v := range lookup(doc, p) {
+			fromPath[ctx] = append(fromPath[ctx], fmt.Sprint(v))
+		}
+	}
+	var entries []completionEntry
+	for _, v := range lookup(doc, field) {
+		entry := completionEntry{weight: 1, contexts: fromPath}
+		if m, ok := v.(map[string]any); ok {
+			for _, in := range asList(m["input"]) {
+				entry.inputs = append(entry.inputs, fmt.Sprint(in))
+			}
+			if w, ok := toFloat(m["weight"]); ok {
+				entry.weight = w
+			}
+			if contexts, ok := m["contexts"].(map[string]any); ok {
+				entry.contexts = make(map[string][]string)
+				for ctx, values := range contexts {
+					for _, cv := range asList(values) {
+						entry.contexts[ctx] = append(entry.contexts[ctx], fmt.Sprint(cv))
+					}
+				}
+			}
+		} else {
+			entry.inputs = []string{fmt.Sprint(v)}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func contextMatch(have, wanted map[string][]string) bool {
+	for ctx, values := range wanted {
+		ok := false
+		for _, w := range values {
+			for _, h := range have[ctx] {
+				if h == w {
+					ok = true
+				}
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzziness 解析模糊度，AUTO 按输入长度取 0、1、2
+func fuzziness(v any, prefix string) int {
+	if f, ok := toFloat(v); ok {
+		return int(f)
+	}
+	var n int
+	if _, err := fmt.Sscan(fmt.Sprint(v), &n); err == nil {
+		return n
+	}
+	switch l := utf8.RuneCountInString(prefix); {
+	case l <= 2:
+		return 0
+	case l <= 5:
+		return 1
+	}
+	return 2
+}
+
+// prefixMatch 判断 input 是否以 prefix 开头，distance >= 0 时允许 input 的前缀与 prefix 有该编辑距离以内的差异
+func prefixMatch(input, prefix string, distance int) bool {
+	if strings.HasPrefix(input, prefix) {
+		return true
+	}
+	if distance <= 0 {
+		return false
+	}
+	in, p := []rune(input), []rune(prefix)
+	for l := max(len(p)-distance, 0); l <= min(len(p)+distance, len(in)); l++ {
+		if editDistance(in[:l], p) <= distance {
+			return true
+		}
+	}
+	return false
+}
+
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
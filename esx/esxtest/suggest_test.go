@@ -0,0 +1,130 @@
+package esxtest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trancecho/open-sdk/esx"
+	"github.com/trancecho/open-sdk/esx/esxtest"
+)
+
+type article struct {
+	Title    string         `json:"title"`
+	Category string         `json:"category"`
+	Suggest  esx.Completion `json:"suggest" es:"contexts:category/category"`
+}
+
+func suggestTexts(list []esx.Suggestion) []string {
+	texts := make([]string, len(list))
+	for i, s := range list {
+		texts[i] = s.Text
+	}
+	return texts
+}
+
+func TestSuggest(t *testing.T) {
+	s := esxtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+	ctx := context.Background()
+	if _, err := c.CreateIndex(ctx, "articles", esx.SpecFor[article](nil)); err != nil {
+		t.Fatal(err)
+	}
+	for id, a := range map[string]article{
+		"1": {Title: "Elasticsearch guide", Category: "tech", Suggest: esx.Completion{Input: []string{"Elasticsearch guide"}, Weight: 5}},
+		"2": {Title: "Elastic band", Category: "life", Suggest: esx.Completion{Input: []string{"Elastic band"}, Weight: 10}},
+		"3": {Title: "Go generics", Category: "tech", Suggest: esx.Completion{Input: []string{"Go generics"}, Weight: 1}},
+	} {
+		s.Put("articles", id, a)
+	}
+
+	cases := []struct {
+		name   string
+		prefix string
+		opts   []esx.SuggestOption
+		want   []string
+	}{
+		{"weight order", "elas", nil, []string{"Elastic band", "Elasticsearch guide"}},
+		{"context", "elas", []esx.SuggestOption{esx.WithSuggestContext("category", "tech")}, []string{"Elasticsearch guide"}},
+		{"fuzzy", "elsa", []esx.SuggestOption{esx.WithFuzzy("")}, []string{"Elastic band", "Elasticsearch guide"}},
+		{"no fuzzy", "elsa", nil, []string{}},
+		{"blank", " ", nil, []string{}},
+	}
+	for _, tc := range cases {
+		list, err := c.Suggest(ctx, "articles", tc.prefix, 5, tc.opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := suggestTexts(list); len(got) != len(tc.want) || len(got) > 0 && got[0] != tc.want[0] {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	list, err := c.Suggest(ctx, "articles", "elas", 1)
+	if err != nil || len(list) != 1 || list[0].ID != "2" {
+		t.Fatalf("size limit: %+v %v", list, err)
+	}
+}
+
+func TestSuggestAsYouType(t *testing.T) {
+	s := esxtest.NewServer()
+	defer s.Close()
+	c := s.Client()
+	ctx := context.Background()
+	spec := esx.SpecFor[post](nil).SetField("title", esx.SearchAsYouTypeMapping())
+	if _, err := c.CreateIndex(ctx, "posts", spec); err != nil {
+		t.Fatal(err)
+	}
+	s.Put("posts", "1", post{Title: "quick brown fox"})
+	s.Put("posts", "2", post{Title: "quiet night"})
+
+	// bool_prefix 不要求词序，只有最后一个词按前缀匹配
+	for _, prefix := range []string{"brown fo", "fox qu"} {
+		list, err := c.Suggest(ctx, "posts", prefix, 5, esx.WithSearchAsYouType("title"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := suggestTexts(list); len(got) != 1 || got[0] != "quick brown fox" {
+			t.Fatalf("%s: unexpected suggestions %v", prefix, got)
+		}
+	}
+}
+
+func TestSuggestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := esxtest.NewServer()
+	defer s.Close()
+	for i := 0; i < 60; i++ {
+		s.Put("articles", fmt.Sprint(i), article{Suggest: esx.Completion{Input: []string{fmt.Sprintf("go %02d", i)}}})
+	}
+	var disabled *esx.Client
+
+	cases := []struct {
+		name   string
+		client *esx.Client
+		n      int
+		query  string
+		want   int
+	}{
+		{"default size", s.Client(), 10, "q=go", 10},
+		{"default capped", s.Client(), 100, "q=go", 50},
+		{"zero default", s.Client(), 0, "q=go", 10},
+		{"size capped", s.Client(), 10, "q=go&size=80", 50},
+		{"disabled", disabled, 10, "q=go", 0},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/suggest?"+tc.query, nil)
+		tc.client.SuggestHandler("articles", tc.n)(ctx)
+		data, _ := ctx.Get("data")
+		list, ok := data.([]esx.Suggestion)
+		if !ok || len(list) != tc.want {
+			t.Errorf("%s: expected %d suggestions, got %v (status %d)", tc.name, tc.want, data, w.Code)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package esx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/refresh"
+)
+
+// Index 类型化的文档仓储，文档与 T 之间按 JSON 转换
+//
+//	users := esx.NewIndex[User](nil, "users")
+//	err := users.Put(ctx, "1", User{Name: "tom"})
+type Index[T any] struct {
+	client *Client
+	name   string
+}
+
+// NewIndex 创建索引 name 上的仓储，client 为 nil 时每次调用使用 GetClient 返回的默认客户端
+func NewIndex[T any](client *Client, name string) *Index[T] {
+	return &Index[T]{client: client, name: name}
+}
+
+// Name 索引名
+func (i *Index[T]) Name() string {
+	return i.name
+}
+
+func (i *Index[T]) c() *Client {
+	if i.client != nil {
+		return i.client
+	}
+	return GetClient()
+}
+
+// SearchResult 类型化的搜索结果
+type SearchResult[T any] struct {
+	Total        int64
+	MaxScore     float64
+	Hits         []SearchHit[T]
+	Aggregations map[string]json.RawMessage
+}
+
+// SearchHit 一条命中的文档
+type SearchHit[T any] struct {
+	ID        string
+	Index     string
+	Score     float64
+	Source    T
+	Highlight map[string][]string
+	Sort      []any // 最后一条的 Sort 可作为下一页的 search_after
+}
+
+// Put 写入（覆盖）文档
+func (i *Index[T]) Put(ctx context.Context, id string, doc T) error {
+	c := i.c()
+	if !c.Enabled() {
+		return nil
+	}
+	req := c.TypedClient.Index(i.name).Id(id).Document(doc)
+	if c.conf.Refresh != "" {
+		req.Refresh(refresh.Refresh{Name: c.conf.Refresh})
+	}
+	if _, err := req.Do(ctx); err != nil {
+		return fmt.Errorf("put %s/%s: %w", i.name, id, typedError(err))
+	}
+	return nil
+}
+
+// Get 读取文档，不存在时返回 ErrNotFound
+func (i *Index[T]) Get(ctx context.Context, id string) (T, error) {
+	var doc T
+	c := i.c()
+	if !c.Enabled() {
+		return doc, ErrDisabled
+	}
+	res, err := c.TypedClient.Get(i.name, id).Do(ctx)
+	if err != nil {
+		return doc, fmt.Errorf("get %s/%s: %w", i.name, id, typedError(err))
+	}
+	if !res.Found {
+		return doc, fmt.Errorf("get %s/%s: %w", i.name, id, ErrNotFound)
+	}
+	if err = json.Unmarshal(res.Source_, &doc); err != nil {
+		return doc, fmt.Errorf("decode %s/%s: %w", i.name, id, err)
+	}
+	return doc, nil
+}
+
+// Update 部分更新文档，partial 中的字段合并到原文档，文档不存在时返回 ErrNotFound
+func (i *Index[T]) Update(ctx context.Context, id string, partial any) error {
+	return i.update(ctx, id, partial, false)
+}
+
+// Upsert 文档存在时合并 doc 的字段，不存在时以 doc 创建
+func (i *Index[T]) Upsert(ctx context.Context, id string, doc T) error {
+	return i.update(ctx, id, doc, true)
+}
+
+func (i *Index[T]) update(ctx context.Context, id string, doc any, upsert bool) error {
+	c := i.c()
+	if !c.Enabled() {
+		return nil
+	}
+	req := c.TypedClient.Update(i.name, id).Doc(doc).RetryOnConflict(3)
+	if upsert {
+		req.DocAsUpsert(true)
+	}
+	if c.conf.Refresh != "" {
+		req.Refresh(refresh.Refresh{Name: c.conf.Refresh})
+	}
+	if _, err := req.Do(ctx); err != nil {
+		return fmt.Errorf("update %s/%s: %w", i.name, id, typedError(err))
+	}
+	return nil
+}
+
+// Delete 删除文档，不存在时返回 ErrNotFound
+func (i *Index[T]) Delete(ctx context.Context, id string) error {
+	c := i.c()
+	if !c.Enabled() {
+		return nil
+	}
+	req := c.TypedClient.Delete(i.name, id)
+	if c.conf.Refresh != "" {
+		req.Refresh(refresh.Refresh{Name: c.conf.Refresh})
+	}
+	res, err := req.Do(ctx)
+	if err != nil {
+		return fmt.Errorf("delete %s/%s: %w", i.name, id, typedError(err))
+	}
+	if res.Result.Name == "not_found" {
+		return fmt.Errorf("delete %s/%s: %w", i.name, id, ErrNotFound)
+	}
+	return nil
+}
+
+// Exists 判断文档是否存在
+func (i *Index[T]) Exists(ctx context.Context, id string) (bool, error) {
+	c := i.c()
+	if !c.Enabled() {
+		return false, ErrDisabled
+	}
+	ok, err := c.TypedClient.Exists(i.name, id).IsSuccess(ctx)
+	if err != nil {
+		return false, fmt.Errorf("exists %s/%s: %w", i.name, id, typedError(err))
+	}
+	return ok, nil
+}
+
+// Search 执行搜索，body 为完整的查询 DSL（map、JSON 字符串等，见 jsonReader）
+func (i *Index[T]) Search(ctx context.Context, body any) (*SearchResult[T], error) {
+	c := i.c()
+	if !c.Enabled() {
+		return nil, ErrDisabled
+	}
+	reader, err := jsonReader(body)
+	if err != nil {
+		return nil, err
+	}
+	// 使用 Perform 自行解析响应，聚合结果保留原始 JSON
+	res, err := c.TypedClient.Search().Index(i.name).TrackTotalHits(true).Raw(reader).Perform(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("search %s: %w", i.name, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("search %s: %w", i.name, httpError(res))
+	}
+	var raw struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			MaxScore *float64 `json:"max_score"`
+			Hits     []struct {
+				Hit
+				Highlight map[string][]string `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]json.RawMessage `json:"aggregations"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error parsing the response body: %w", err)
+	}
+	result := &SearchResult[T]{
+		Total:        raw.Hits.Total.Value,
+		Hits:         make([]SearchHit[T], 0, len(raw.Hits.Hits)),
+		Aggregations: raw.Aggregations,
+	}
+	if raw.Hits.MaxScore != nil {
+		result.MaxScore = *raw.Hits.MaxScore
+	}
+	for _, h := range raw.Hits.Hits {
+		hit := SearchHit[T]{ID: h.ID, Index: h.Index, Score: h.Score, Highlight: h.Highlight, Sort: h.Sort}
+		if len(h.Source) > 0 {
+			if err = json.Unmarshal(h.Source, &hit.Source); err != nil {
+				return nil, fmt.Errorf("decode %s/%s: %w", i.name, h.ID, err)
+			}
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
@@ -0,0 +1,269 @@
+package esx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// PutIndexTemplate 创建或更新索引模板，匹配 patterns 的新索引自动应用 spec
+func (c *Client) PutIndexTemplate(ctx context.Context, name string, patterns []string, spec IndexSpec) error {
+	if !c.Enabled() {
+		return nil
+	}
+	body, err := jsonReader(map[string]any{"index_patterns": patterns, "template": spec})
+	if err != nil {
+		return err
+	}
+	if err = c.do(ctx, esapi.IndicesPutIndexTemplateRequest{Name: name, Body: body}, nil); err != nil {
+		return fmt.Errorf("put index template %s: %w", name, err)
+	}
+	return nil
+}
+
+// AliasIndices 返回别名当前指向的索引，别名不存在时返回空
+func (c *Client) AliasIndices(ctx context.Context, alias string) ([]string, error) {
+	indices, _, err := c.aliasTargets(ctx, alias)
+	return indices, err
+}
+
+// aliasTargets 返回别名指向的索引及其中标记了 is_write_index 的索引
+func (c *Client) aliasTargets(ctx context.Context, alias string) ([]string, string, error) {
+	if !c.Enabled() {
+		return nil, "", ErrDisabled
+	}
+	var result map[string]struct {
+		Aliases map[string]struct {
+			IsWriteIndex bool `json:"is_write_index"`
+		} `json:"aliases"`
+	}
+	err := c.do(ctx, esapi.IndicesGetAliasRequest{Name: []string{alias}}, &result)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("get alias %s: %w", alias, err)
+	}
+	indices := make([]string, 0, len(result))
+	write := ""
+	for index, info := range result {
+		indices = append(indices, index)
+		if info.Aliases[alias].IsWriteIndex {
+			write = index
+		}
+	}
+	sort.Strings(indices)
+	return indices, write, nil
+}
+
+// EnsureAlias 保证别名存在：不存在时以 spec 创建 <alias>_v1 并把别名指向它，
+// 已存在时不做修改。返回别名当前的写索引，未标记写索引时返回版本号最大的索引；
+// 已有同名的具体索引时返回 ErrAliasConflict
+func (c *Client) EnsureAlias(ctx context.Context, alias string, spec IndexSpec) (string, error) {
+	if !c.Enabled() {
+		return alias, nil
+	}
+	indices, write, err := c.aliasTargets(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	if write != "" {
+		return write, nil
+	}
+	if len(indices) > 0 {
+		// 没有显式的写索引时取版本号最大的索引，按数字比较，posts_v10 新于 posts_v9
+		latest := indices[len(indices)-1]
+		for _, index := range indices {
+			if versionOf(alias, index) > versionOf(alias, latest) {
+				latest = index
+			}
+		}
+		return latest, nil
+	}
+	res, err := c.Client.Indices.Exists([]string{alias}, c.Client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("check index %s: %w", alias, err)
+	}
+	res.Body.Close()
+	if res.StatusCode == 200 {
+		return "", fmt.Errorf("alias %s: %w, reindex it into a versioned index and delete it first", alias, ErrAliasConflict)
+	}
+	index, err := c.nextVersion(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	if _, err = c.CreateIndex(ctx, index, spec); err != nil {
+		return "", err
+	}
+	if err = c.swapAlias(ctx, alias, nil, index); err != nil {
+		return "", err
+	}
+	log.Printf("Index %s created behind alias %s", index, alias)
+	return index, nil
+}
+
+type reindexOptions struct {
+	deleteOld    bool
+	pollInterval time.Duration
+}
+
+type ReindexOption func(*reindexOptions)
+
+// WithDeleteOld 切换别名后删除旧索引，默认保留以便回滚
+func WithDeleteOld() ReindexOption {
+	return func(o *reindexOptions) {
+		o.deleteOld = true
+	}
+}
+
+// WithReindexPollInterval 查询重建任务进度的间隔，默认 2s
+func WithReindexPollInterval(d time.Duration) ReindexOption {
+	return func(o *reindexOptions) {
+		o.pollInterval = d
+	}
+}
+
+// Reindex 以 spec 创建别名的下一个版本索引，把当前索引的数据复制过去后原子切换别名，
+// 用于修改 mappings、分词器等不能原地修改的配置。
+// 复制期间写入旧索引的文档不会出现在新索引中，调用方需暂停写入或在切换后重新同步这段时间的变更
+func (c *Client) Reindex(ctx context.Context, alias string, spec IndexSpec, opts ...ReindexOption) (string, error) {
+	o := reindexOptions{pollInterval: 2 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !c.Enabled() {
+		return alias, nil
+	}
+	old, err := c.AliasIndices(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	if len(old) == 0 {
+		return "", fmt.Errorf("alias %s: %w", alias, ErrNotFound)
+	}
+	index, err := c.nextVersion(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	if _, err = c.CreateIndex(ctx, index, spec); err != nil {
+		return "", err
+	}
+
+	body, err := jsonReader(map[string]any{
+		"source":    map[string]any{"index": old},
+		"dest":      map[string]any{"index": index},
+		"conflicts": "proceed",
+	})
+	if err != nil {
+		return "", err
+	}
+	wait := false
+	var task struct {
+		Task string `json:"task"`
+	}
+	if err = c.do(ctx, esapi.ReindexRequest{Body: body, WaitForCompletion: &wait}, &task); err != nil {
+		return "", fmt.Errorf("reindex %s to %s: %w", alias, index, err)
+	}
+	if err = c.waitTask(ctx, task.Task, o.pollInterval); err != nil {
+		return "", fmt.Errorf("reindex %s to %s: %w", alias, index, err)
+	}
+	if err = c.do(ctx, esapi.IndicesRefreshRequest{Index: []string{index}}, nil); err != nil {
+		return "", fmt.Errorf("refresh %s: %w", index, err)
+	}
+	if err = c.swapAlias(ctx, alias, old, index); err != nil {
+		return "", err
+	}
+	log.Printf("Alias %s switched from %s to %s", alias, strings.Join(old, ","), index)
+	if o.deleteOld {
+		if err = c.do(ctx, esapi.IndicesDeleteRequest{Index: old}, nil); err != nil {
+			return index, fmt.Errorf("delete old index %s: %w", strings.Join(old, ","), err)
+		}
+	}
+	return index, nil
+}
+
+// waitTask 轮询任务直到完成，任务失败或有文档复制失败时返回错误
+func (c *Client) waitTask(ctx context.Context, taskID string, interval time.Duration) error {
+	for {
+		var status struct {
+			Completed bool `json:"completed"`
+			Error     *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+			Response struct {
+				Total    int64             `json:"total"`
+				Failures []json.RawMessage `json:"failures"`
+			} `json:"response"`
+		}
+		if err := c.do(ctx, esapi.TasksGetRequest{TaskID: taskID}, &status); err != nil {
+			return fmt.Errorf("get task %s: %w", taskID, err)
+		}
+		if status.Completed {
+			if status.Error != nil {
+				return &ResponseError{Status: 500, Type: status.Error.Type, Reason: status.Error.Reason}
+			}
+			if n := len(status.Response.Failures); n > 0 {
+				return fmt.Errorf("%d documents failed, first: %s", n, status.Response.Failures[0])
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// swapAlias 在一个请求中把别名从 old 移到 index，保证查询不会看到中间状态
+func (c *Client) swapAlias(ctx context.Context, alias string, old []string, index string) error {
+	actions := make([]map[string]any, 0, len(old)+1)
+	for _, o := range old {
+		actions = append(actions, map[string]any{"remove": map[string]any{"index": o, "alias": alias}})
+	}
+	actions = append(actions, map[string]any{"add": map[string]any{"index": index, "alias": alias, "is_write_index": true}})
+	body, err := jsonReader(map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+	if err = c.do(ctx, esapi.IndicesUpdateAliasesRequest{Body: body}, nil); err != nil {
+		return fmt.Errorf("update alias %s: %w", alias, err)
+	}
+	return nil
+}
+
+// nextVersion 返回 <alias>_v<n+1>，n 为已存在的最大版本
+func (c *Client) nextVersion(ctx context.Context, alias string) (string, error) {
+	var indices []struct {
+		Index string `json:"index"`
+	}
+	req := esapi.CatIndicesRequest{Index: []string{alias + "_v*"}, Format: "json", H: []string{"index"}}
+	if err := c.do(ctx, req, &indices); err != nil && !errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("list versions of %s: %w", alias, err)
+	}
+	version := 0
+	for _, idx := range indices {
+		version = max(version, versionOf(alias, idx.Index))
+	}
+	return fmt.Sprintf("%s_v%d", alias, version+1), nil
+}
+
+// versionOf 返回 <alias>_v<n> 中的 n，不是该别名的版本索引时返回 0
+func versionOf(alias, index string) int {
+	m := regexp.MustCompile("^" + regexp.QuoteMeta(alias) + `_v(\d+)$`).FindStringSubmatch(index)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
@@ -0,0 +1,161 @@
+package esx
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// IndexSpec 索引的 settings 和 mappings，可由 JSON 解析或从结构体生成
+type IndexSpec struct {
+	Settings map[string]any `json:"settings,omitempty"`
+	Mappings map[string]any `json:"mappings,omitempty"`
+}
+
+// ParseIndexSpec 从 JSON 解析，格式与创建索引的请求体相同
+func ParseIndexSpec(data []byte) (IndexSpec, error) {
+	var spec IndexSpec
+	err := json.Unmarshal(data, &spec)
+	return spec, err
+}
+
+// SpecFor 根据 T 的字段生成 mappings，settings 可为 nil
+func SpecFor[T any](settings map[string]any) IndexSpec {
+	var v T
+	return IndexSpec{Settings: settings, Mappings: MappingOf(v)}
+}
+
+// MappingOf 根据结构体字段生成 mappings，字段名取 json 标签。
+// 类型按 Go 类型推断：string 为 text、整数为 long、浮点数为 double、time.Time 为 date，
+// []byte 为 binary，嵌套结构体为 object，自引用的结构体字段交给动态映射；可用 es 标签覆盖，多个选项以 ; 分隔，值为 - 时忽略该字段：
+//
+//	Title  string   `json:"title" es:"analyzer:ik_max_word;search_analyzer:ik_smart"`
+//	Status string   `json:"status" es:"type:keyword"`
+//	Tags   []string `json:"tags" es:"type:keyword"`
+//	Body   string   `json:"body" es:"index:false"`
+//	Name   string   `json:"name" es:"type:search_as_you_type"`
+//
+// Completion 类型的字段映射为 completion，contexts 选项声明分类上下文，以 , 分隔，name/path 表示上下文取自文档字段：
+//
+//	Suggest Completion `json:"suggest" es:"contexts:category/tag,tenant"`
+func MappingOf(v any) map[string]any {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	return map[string]any{"properties": properties(t, make(map[reflect.Type]bool))}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	completionType = reflect.TypeOf(Completion{})
+	rawType        = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// isBytes 字节切片按 base64 编码，json.RawMessage 除外
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && t != rawType
+}
+
+// properties seen 为当前路径上正在展开的结构体，避免自引用类型无限递归
+func properties(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	seen[t] = true
+	defer delete(seen, t)
+	props := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || f.Tag.Get("es") == "-" {
+			continue
+		}
+		ft := f.Type
+		for !isBytes(ft) && ft != rawType && (ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array) {
+			ft = ft.Elem()
+		}
+		// 匿名嵌入且没有 json 名称的结构体，字段提升到当前层级
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType && ft != completionType {
+			if seen[ft] {
+				continue
+			}
+			for k, v := range properties(ft, seen) {
+				props[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := fieldMapping(ft, seen)
+		for _, opt := range strings.Split(f.Tag.Get("es"), ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(opt), ":")
+			if !ok || key == "" {
+				continue
+			}
+			switch {
+			case key == "contexts":
+				prop[key] = suggestContexts(value)
+			case value == "true":
+				prop[key] = true
+			case value == "false":
+				prop[key] = false
+			default:
+				prop[key] = value
+			}
+		}
+		// 显式指定为非 object 类型时去掉推断出的子属性
+		if typ, _ := prop["type"].(string); typ != "" && typ != "object" && typ != "nested" {
+			delete(prop, "properties")
+		}
+		if len(prop) > 0 {
+			props[name] = prop
+		}
+	}
+	return props
+}
+
+func fieldMapping(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	switch {
+	case isBytes(t):
+		return map[string]any{"type": "binary"}
+	case t == timeType:
+		return map[string]any{"type": "date"}
+	case t == completionType:
+		return map[string]any{"type": "completion"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "text"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "long"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "double"}
+	case t.Kind() == reflect.Struct && !seen[t]:
+		return map[string]any{"properties": properties(t, seen)}
+	}
+	// map、interface 等交给集群动态映射
+	return map[string]any{}
+}
+
+// suggestContexts 解析 contexts 选项，如 category/tag,tenant
+func suggestContexts(value string) []map[string]any {
+	var contexts []map[string]any
+	for _, c := range strings.Split(value, ",") {
+		name, path, _ := strings.Cut(strings.TrimSpace(c), "/")
+		if name == "" {
+			continue
+		}
+		ctx := map[string]any{"name": name, "type": "category"}
+		if path != "" {
+			ctx["path"] = path
+		}
+		contexts = append(contexts, ctx)
+	}
+	return contexts
+}
@@ -0,0 +1,455 @@
+package esx
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/trancecho/open-sdk/pkg/utils/page"
+)
+
+// Query 查询子句
+type Query interface {
+	Source() map[string]any
+}
+
+// RawQuery 直接以 map 表示的查询子句，用于构造器未覆盖的查询类型
+type RawQuery map[string]any
+
+func (q RawQuery) Source() map[string]any {
+	return q
+}
+
+// MatchAll 匹配全部文档
+func MatchAll() Query {
+	return RawQuery{"match_all": map[string]any{}}
+}
+
+// Term 精确匹配，用于 keyword、数字、日期等字段
+func Term(field string, value any) Query {
+	return RawQuery{"term": map[string]any{field: value}}
+}
+
+// Terms 匹配任意一个值，只传入一个切片时按切片元素匹配
+//
+//	esx.Terms("tags", "a", "b")
+//	esx.Terms("tags", []string{"a", "b"})
+func Terms(field string, values ...any) Query {
+	if len(values) == 1 {
+		if rv := reflect.ValueOf(values[0]); (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) &&
+			rv.Type().Elem().Kind() != reflect.Uint8 {
+			list := make([]any, rv.Len())
+			for i := range list {
+				list[i] = rv.Index(i).Interface()
+			}
+			values = list
+		}
+	}
+	return RawQuery{"terms": map[string]any{field: values}}
+}
+
+// TermsOf 匹配任意一个值，用于已有的类型化切片
+func TermsOf[T any](field string, values ...T) Query {
+	return RawQuery{"terms": map[string]any{field: values}}
+}
+
+// Match 全文匹配
+func Match(field string, text any) Query {
+	return RawQuery{"match": map[string]any{field: text}}
+}
+
+// MatchFuzzy 允许拼写错误的全文匹配，模糊度自动处理
+func MatchFuzzy(field string, text string) Query {
+	return RawQuery{"match": map[string]any{field: map[string]any{"query": text, "fuzziness": "AUTO"}}}
+}
+
+// MatchPhrase 短语匹配
+func MatchPhrase(field string, text string) Query {
+	return RawQuery{"match_phrase": map[string]any{field: text}}
+}
+
+// MultiMatch 在多个字段上全文匹配，字段可带权重，如 "title^2"
+func MultiMatch(text string, fields ...string) Query {
+	return RawQuery{"multi_match": map[string]any{"query": text, "fields": fields}}
+}
+
+// Exists 字段有值
+func Exists(field string) Query {
+	return RawQuery{"exists": map[string]any{"field": field}}
+}
+
+// RangeQuery 范围查询
+//
+//	esx.Range("age").Gte(18).Lt(60)
+type RangeQuery struct {
+	field  string
+	params map[string]any
+}
+
+func Range(field string) *RangeQuery {
+	return &RangeQuery{field: field, params: make(map[string]any)}
+}
+
+func (q *RangeQuery) Gt(v any) *RangeQuery {
+	q.params["gt"] = v
+	return q
+}
+
+func (q *RangeQuery) Gte(v any) *RangeQuery {
+	q.params["gte"] = v
+	return q
+}
+
+func (q *RangeQuery) Lt(v any) *RangeQuery {
+	q.params["lt"] = v
+	return q
+}
+
+func (q *RangeQuery) Lte(v any) *RangeQuery {
+	q.params["lte"] = v
+	return q
+}
+
+// Format 日期字段的格式，如 yyyy-MM-dd
+func (q *RangeQuery) Format(format string) *RangeQuery {
+	q.params["format"] = format
+	return q
+}
+
+func (q *RangeQuery) Source() map[string]any {
+	return map[string]any{"range": map[string]any{q.field: q.params}}
+}
+
+// BoolQuery 组合查询：Must 和 Should 参与评分，Filter 和 MustNot 不参与评分
+type BoolQuery struct {
+	must, should, filter, mustNot []Query
+	minimumShouldMatch            any
+}
+
+func Bool() *BoolQuery {
+	return &BoolQuery{}
+}
+
+func (q *BoolQuery) Must(queries ...Query) *BoolQuery {
+	q.must = append(q.must, queries...)
+	return q
+}
+
+func (q *BoolQuery) Should(queries ...Query) *BoolQuery {
+	q.should = append(q.should, queries...)
+	return q
+}
+
+func (q *BoolQuery) Filter(queries ...Query) *BoolQuery {
+	q.filter = append(q.filter, queries...)
+	return q
+}
+
+func (q *BoolQuery) MustNot(queries ...Query) *BoolQuery {
+	q.mustNot = append(q.mustNot, queries...)
+	return q
+}
+
+// MinimumShouldMatch 至少满足的 Should 子句数，可以是数字或 "75%" 这样的比例
+func (q *BoolQuery) MinimumShouldMatch(v any) *BoolQuery {
+	q.minimumShouldMatch = v
+	return q
+}
+
+func (q *BoolQuery) Source() map[string]any {
+	body := make(map[string]any)
+	for name, clauses := range map[string][]Query{"must": q.must, "should": q.should, "filter": q.filter, "must_not": q.mustNot} {
+		if len(clauses) > 0 {
+			body[name] = sources(clauses)
+		}
+	}
+	if q.minimumShouldMatch != nil {
+		body["minimum_should_match"] = q.minimumShouldMatch
+	}
+	return map[string]any{"bool": body}
+}
+
+func sources(queries []Query) []map[string]any {
+	list := make([]map[string]any, len(queries))
+	for i, q := range queries {
+		list[i] = q.Source()
+	}
+	return list
+}
+
+// Aggregation 聚合
+type Aggregation interface {
+	Source() map[string]any
+}
+
+// Agg 通用聚合，kind 为聚合类型，params 为参数，可通过 Sub 添加子聚合
+//
+//	esx.TermsAgg("city", 10).Sub("avg_age", esx.MetricAgg("avg", "age"))
+type Agg struct {
+	kind   string
+	params map[string]any
+	subs   map[string]Aggregation
+}
+
+func NewAgg(kind string, params map[string]any) *Agg {
+	return &Agg{kind: kind, params: params}
+}
+
+// TermsAgg 按字段值分桶
+func TermsAgg(field string, size int) *Agg {
+	return NewAgg("terms", map[string]any{"field": field, "size": size})
+}
+
+// DateHistogramAgg 按日期间隔分桶，interval 如 day、month
+func DateHistogramAgg(field, interval string) *Agg {
+	return NewAgg("date_histogram", map[string]any{"field": field, "calendar_interval": interval})
+}
+
+// MetricAgg 单值指标聚合，kind 为 avg、sum、min、max、cardinality、value_count 等
+func MetricAgg(kind, field string) *Agg {
+	return NewAgg(kind, map[string]any{"field": field})
+}
+
+// Sub 添加子聚合
+func (a *Agg) Sub(name string, agg Aggregation) *Agg {
+	if a.subs == nil {
+		a.subs = make(map[string]Aggregation)
+	}
+	a.subs[name] = agg
+	return a
+}
+
+func (a *Agg) Source() map[string]any {
+	body := map[string]any{a.kind: a.params}
+	if len(a.subs) > 0 {
+		subs := make(map[string]any, len(a.subs))
+		for name, sub := range a.subs {
+			subs[name] = sub.Source()
+		}
+		body["aggs"] = subs
+	}
+	return body
+}
+
+// Bucket 分桶聚合的一个桶，子聚合结果保留原始 JSON
+type Bucket struct {
+	Key         any    `json:"key"`
+	KeyAsString string `json:"key_as_string,omitempty"`
+	DocCount    int64  `json:"doc_count"`
+	Aggs        map[string]json.RawMessage
+}
+
+// ParseBuckets 解析 terms、date_histogram 等分桶聚合的结果
+func ParseBuckets(raw json.RawMessage) ([]Bucket, error) {
+	var result struct {
+		Buckets []map[string]json.RawMessage `json:"buckets"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	buckets := make([]Bucket, 0, len(result.Buckets))
+	for _, fields := range result.Buckets {
+		var b Bucket
+		for name, value := range fields {
+			var err error
+			switch name {
+			case "key":
+				err = json.Unmarshal(value, &b.Key)
+			case "key_as_string":
+				err = json.Unmarshal(value, &b.KeyAsString)
+			case "doc_count":
+				err = json.Unmarshal(value, &b.DocCount)
+			default:
+				if len(value) > 0 && value[0] == '{' {
+					if b.Aggs == nil {
+						b.Aggs = make(map[string]json.RawMessage)
+					}
+					b.Aggs[name] = value
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// ParseMetric 解析 avg、sum 等单值指标聚合的结果，没有数据时返回 0
+func ParseMetric(raw json.RawMessage) (float64, error) {
+	var result struct {
+		Value *float64 `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return 0, err
+	}
+	if result.Value == nil {
+		return 0, nil
+	}
+	return *result.Value, nil
+}
+
+// SearchRequest 搜索请求构造器，可直接作为 Client.Search 和 Index.Search 的 body
+//
+//	req := esx.NewSearch().
+//		Query(esx.Bool().Must(esx.Match("title", kw)).Filter(esx.Term("status", 1))).
+//		Sort("-created_at", "id").Size(20).Highlight("title")
+type SearchRequest struct {
+	query       Query
+	sort        []map[string]any
+	from, size  *int
+	searchAfter []any
+	highlight   map[string]any
+	includes    []string
+	excludes    []string
+	noSource    bool
+	aggs        map[string]Aggregation
+}
+
+func NewSearch() *SearchRequest {
+	return &SearchRequest{}
+}
+
+func (r *SearchRequest) Query(q Query) *SearchRequest {
+	r.query = q
+	return r
+}
+
+// Sort 追加排序字段，前缀 - 表示倒序，与 page.ParseSort 的格式一致
+func (r *SearchRequest) Sort(fields ...string) *SearchRequest {
+	for _, c := range page.ParseSort(fields...) {
+		order := "asc"
+		if c.Desc {
+			order = "desc"
+		}
+		r.sort = append(r.sort, map[string]any{c.Column: map[string]any{"order": order}})
+	}
+	return r
+}
+
+func (r *SearchRequest) From(from int) *SearchRequest {
+	r.from = &from
+	return r
+}
+
+func (r *SearchRequest) Size(size int) *SearchRequest {
+	r.size = &size
+	return r
+}
+
+// Page 偏移分页，page 从 1 开始，取值规则同 page.Paginate；深分页应改用 SearchAfter
+func (r *SearchRequest) Page(p, limit int) *SearchRequest {
+	p, limit = page.Normalize(p, limit)
+	return r.From((p - 1) * limit).Size(limit)
+}
+
+// SearchAfter 从上一页最后一条命中的 Sort 值之后继续，需要配合包含唯一字段的 Sort 使用
+func (r *SearchRequest) SearchAfter(values ...any) *SearchRequest {
+	r.searchAfter = values
+	return r
+}
+
+// Highlight 高亮字段，默认使用 <em></em> 标记
+func (r *SearchRequest) Highlight(fields ...string) *SearchRequest {
+	if r.highlight == nil {
+		r.highlight = map[string]any{"fields": map[string]any{}}
+	}
+	for _, f := range fields {
+		r.highlight["fields"].(map[string]any)[f] = map[string]any{}
+	}
+	return r
+}
+
+// HighlightTags 自定义高亮标记
+func (r *SearchRequest) HighlightTags(pre, post string) *SearchRequest {
+	if r.highlight == nil {
+		r.highlight = map[string]any{"fields": map[string]any{}}
+	}
+	r.highlight["pre_tags"], r.highlight["post_tags"] = []string{pre}, []string{post}
+	return r
+}
+
+// Includes 只返回 _source 中的这些字段，支持通配符
+func (r *SearchRequest) Includes(fields ...string) *SearchRequest {
+	r.includes = append(r.includes, fields...)
+	return r
+}
+
+// Excludes 不返回 _source 中的这些字段
+func (r *SearchRequest) Excludes(fields ...string) *SearchRequest {
+	r.excludes = append(r.excludes, fields...)
+	return r
+}
+
+// NoSource 不返回 _source，只需要 id 或聚合结果时使用
+func (r *SearchRequest) NoSource() *SearchRequest {
+	r.noSource = true
+	return r
+}
+
+// Aggregation 添加命名聚合，结果在 SearchResult.Aggregations[name] 中
+func (r *SearchRequest) Aggregation(name string, agg Aggregation) *SearchRequest {
+	if r.aggs == nil {
+		r.aggs = make(map[string]Aggregation)
+	}
+	r.aggs[name] = agg
+	return r
+}
+
+// Build 生成请求体
+func (r *SearchRequest) Build() map[string]any {
+	body := make(map[string]any)
+	if r.query != nil {
+		body["query"] = r.query.Source()
+	}
+	if len(r.sort) > 0 {
+		body["sort"] = r.sort
+	}
+	if r.from != nil && r.searchAfter == nil {
+		body["from"] = *r.from
+	}
+	if r.size != nil {
+		body["size"] = *r.size
+	}
+	if r.searchAfter != nil {
+		body["search_after"] = r.searchAfter
+	}
+	if r.highlight != nil {
+		body["highlight"] = r.highlight
+	}
+	switch {
+	case r.noSource:
+		body["_source"] = false
+	case len(r.includes) > 0 || len(r.excludes) > 0:
+		source := make(map[string]any)
+		if len(r.includes) > 0 {
+			source["includes"] = r.includes
+		}
+		if len(r.excludes) > 0 {
+			source["excludes"] = r.excludes
+		}
+		body["_source"] = source
+	}
+	if len(r.aggs) > 0 {
+		aggs := make(map[string]any, len(r.aggs))
+		for name, agg := range r.aggs {
+			aggs[name] = agg.Source()
+		}
+		body["aggs"] = aggs
+	}
+	return body
+}
+
+func (r *SearchRequest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Build())
+}
+
+// String 返回请求体 JSON，便于调试
+func (r *SearchRequest) String() string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(r.Build())
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,248 @@
+package esx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/gin-gonic/gin"
+	"github.com/trancecho/open-sdk/libx"
+)
+
+// Completion completion 类型字段的值，Weight 越大越靠前，Contexts 为分类上下文 => 取值
+type Completion struct {
+	Input    []string            `json:"input"`
+	Weight   int                 `json:"weight,omitempty"`
+	Contexts map[string][]string `json:"contexts,omitempty"`
+}
+
+// CompletionMapping 返回 completion 字段的映射，contexts 为分类上下文名称，用于 IndexSpec.SetField
+func CompletionMapping(contexts ...string) map[string]any {
+	prop := map[string]any{"type": "completion"}
+	if len(contexts) > 0 {
+		prop["contexts"] = suggestContexts(strings.Join(contexts, ","))
+	}
+	return prop
+}
+
+// SearchAsYouTypeMapping 返回 search_as_you_type 字段的映射
+func SearchAsYouTypeMapping() map[string]any {
+	return map[string]any{"type": "search_as_you_type"}
+}
+
+// SetField 设置顶层字段的映射，用于补充或覆盖 SpecFor 生成的映射
+func (s IndexSpec) SetField(name string, mapping map[string]any) IndexSpec {
+	if s.Mappings == nil {
+		s.Mappings = make(map[string]any)
+	}
+	props, _ := s.Mappings["properties"].(map[string]any)
+	if props == nil {
+		props = make(map[string]any)
+		s.Mappings["properties"] = props
+	}
+	props[name] = mapping
+	return s
+}
+
+// Suggestion 一条联想结果
+type Suggestion struct {
+	Text   string          `json:"text"`
+	ID     string          `json:"id"`
+	Score  float64         `json:"score"`
+	Source json.RawMessage `json:"source,omitempty"`
+}
+
+type suggestOptions struct {
+	field           string
+	searchAsYouType bool
+	fuzziness       string
+	contexts        map[string][]string
+	contextParams   []string
+}
+
+type SuggestOption func(*suggestOptions)
+
+// WithSuggestField 联想使用的字段，默认 suggest
+func WithSuggestField(field string) SuggestOption {
+	return func(o *suggestOptions) {
+		o.field = field
+	}
+}
+
+// WithSearchAsYouType 使用 search_as_you_type 字段联想，field 为该字段名。
+// 与 completion 相比可以匹配输入中间的词，结果文本为文档中该字段的值
+func WithSearchAsYouType(field string) SuggestOption {
+	return func(o *suggestOptions) {
+		o.field = field
+		o.searchAsYouType = true
+	}
+}
+
+// WithFuzzy 允许输入有拼写错误，fuzziness 如 AUTO、1、2，为空时使用 AUTO
+func WithFuzzy(fuzziness string) SuggestOption {
+	return func(o *suggestOptions) {
+		if fuzziness == "" {
+			fuzziness = "AUTO"
+		}
+		o.fuzziness = fuzziness
+	}
+}
+
+// WithSuggestContext 只返回上下文 name 取值为 values 之一的结果。
+// completion 字段按映射中声明的上下文过滤，search_as_you_type 按同名字段过滤
+func WithSuggestContext(name string, values ...string) SuggestOption {
+	return func(o *suggestOptions) {
+		if o.contexts == nil {
+			o.contexts = make(map[string][]string)
+		}
+		o.contexts[name] = append(o.contexts[name], values...)
+	}
+}
+
+// WithContextParams SuggestHandler 把这些同名的查询参数作为上下文过滤条件
+func WithContextParams(names ...string) SuggestOption {
+	return func(o *suggestOptions) {
+		o.contextParams = append(o.contextParams, names...)
+	}
+}
+
+// Suggest 返回以 prefix 开头的至多 n 条联想结果，默认使用名为 suggest 的 completion 字段
+//
+//	list, err := client.Suggest(ctx, "posts", "elas", 10, esx.WithFuzzy(""), esx.WithSuggestContext("category", "tech"))
+func (c *Client) Suggest(ctx context.Context, index, prefix string, n int, opts ...SuggestOption) ([]Suggestion, error) {
+	o := suggestOptions{field: "suggest"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !c.Enabled() {
+		return nil, ErrDisabled
+	}
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" || n <= 0 {
+		return []Suggestion{}, nil
+	}
+	if o.searchAsYouType {
+		return c.suggestAsYouType(ctx, index, prefix, n, o)
+	}
+
+	completion := map[string]any{"field": o.field, "size": n, "skip_duplicates": true}
+	if o.fuzziness != "" {
+		completion["fuzzy"] = map[string]any{"fuzziness": o.fuzziness}
+	}
+	if len(o.contexts) > 0 {
+		completion["contexts"] = o.contexts
+	}
+	body, err := jsonReader(map[string]any{
+		"size":    0,
+		"suggest": map[string]any{"suggestion": map[string]any{"prefix": prefix, "completion": completion}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Suggest map[string][]struct {
+			Options []struct {
+				Text   string          `json:"text"`
+				ID     string          `json:"_id"`
+				Score  float64         `json:"_score"`
+				Source json.RawMessage `json:"_source"`
+			} `json:"options"`
+		} `json:"suggest"`
+	}
+	if err = c.do(ctx, esapi.SearchRequest{Index: []string{index}, Body: body}, &result); err != nil {
+		return nil, fmt.Errorf("suggest %s: %w", index, err)
+	}
+	list := make([]Suggestion, 0, n)
+	for _, entry := range result.Suggest["suggestion"] {
+		for _, opt := range entry.Options {
+			list = append(list, Suggestion{Text: opt.Text, ID: opt.ID, Score: opt.Score, Source: opt.Source})
+		}
+	}
+	return list, nil
+}
+
+// suggestAsYouType 在 search_as_you_type 字段及其 n-gram 子字段上做 bool_prefix 查询
+func (c *Client) suggestAsYouType(ctx context.Context, index, prefix string, n int, o suggestOptions) ([]Suggestion, error) {
+	match := map[string]any{
+		"query":    prefix,
+		"type":     "bool_prefix",
+		"operator": "and",
+		"fields":   []string{o.field, o.field + "._2gram", o.field + "._3gram"},
+	}
+	if o.fuzziness != "" {
+		match["fuzziness"] = o.fuzziness
+	}
+	query := Bool().Must(RawQuery{"multi_match": match})
+	for name, values := range o.contexts {
+		query.Filter(TermsOf(name, values...))
+	}
+	res, err := c.Search(ctx, index, NewSearch().Query(query).Size(n))
+	if err != nil {
+		return nil, fmt.Errorf("suggest %s: %w", index, err)
+	}
+	list := make([]Suggestion, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		var doc map[string]any
+		_ = json.Unmarshal(h.Source, &doc)
+		text, _ := fieldValue(doc, o.field).(string)
+		list = append(list, Suggestion{Text: text, ID: h.ID, Score: h.Score, Source: h.Source})
+	}
+	return list, nil
+}
+
+// fieldValue 按点分路径取文档字段
+func fieldValue(doc map[string]any, path string) any {
+	var v any = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
+}
+
+// SuggestHandler 返回联想接口的 gin 处理函数：查询参数 q 为输入，size 为数量（默认 n，n <= 0 时为 10，最多 50），
+// 结果以 libx.Ok 返回 Suggestion 列表，客户端未启用时返回空列表
+//
+//	r.GET("/posts/suggest", client.SuggestHandler("posts", 10, esx.WithContextParams("category")))
+func (c *Client) SuggestHandler(index string, n int, opts ...SuggestOption) gin.HandlerFunc {
+	var o suggestOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if n <= 0 {
+		n = 10
+	}
+	return func(ctx *gin.Context) {
+		if !c.Enabled() {
+			libx.Ok(ctx, "获取搜索建议成功", []Suggestion{})
+			return
+		}
+		size := min(n, 50)
+		if s := ctx.Query("size"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v <= 0 {
+				libx.Err(ctx, 400000, "size 参数错误", libx.ErrOptions{})
+				return
+			}
+			size = min(v, 50)
+		}
+		reqOpts := opts
+		for _, name := range o.contextParams {
+			if values := ctx.QueryArray(name); len(values) > 0 {
+				reqOpts = append(reqOpts[:len(reqOpts):len(reqOpts)], WithSuggestContext(name, values...))
+			}
+		}
+		list, err := c.Suggest(ctx.Request.Context(), index, ctx.Query("q"), size, reqOpts...)
+		if err != nil {
+			libx.Err(ctx, 500000, "获取搜索建议失败", libx.ErrOptions{Err: err})
+			return
+		}
+		libx.Ok(ctx, "获取搜索建议成功", list)
+	}
+}
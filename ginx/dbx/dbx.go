@@ -6,10 +6,13 @@ import (
 	"log"
 )
 
+// Key 主数据源的 Key，需在 InitDB 之前修改
+var Key = "MainMysql"
+
 var DB *gorm.DB
 
 func InitDB() {
-	DB = database.GetDb("MainMysql")
+	DB = database.GetDb(Key)
 	if DB == nil {
 		log.Fatalln("failed to connect database")
 	}
@@ -17,4 +20,9 @@ func InitDB() {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	// 注册到各数据源的模型按 DbKey 分别建表，不局限于主数据源
+	err = database.AutoMigrate()
+	if err != nil {
+		log.Fatalln(err)
+	}
 }
@@ -1,8 +1,18 @@
 package dbx
 
-import "gorm.io/gorm"
+import (
+	"context"
+	"github.com/trancecho/open-sdk/database/migrate"
+	"gorm.io/gorm"
+)
+
+// Migrations 服务在 InitDB 之前注册的迁移，InitDB 时自动执行
+var Migrations []migrate.Migration
 
 func AutoMigrate(db *gorm.DB) error {
-	err := db.AutoMigrate()
+	if len(Migrations) == 0 {
+		return nil
+	}
+	_, err := migrate.New(db, migrate.WithMigrations(Migrations...)).Up(context.Background())
 	return err
 }
@@ -0,0 +1,61 @@
+package logx
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+type requestIDKey struct{}
+type userIDKey struct{}
+
+// WithRequestID 把请求 ID 放入 context，日志会自动携带
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// WithUserID 把用户 ID 放入 context，日志会自动携带
+func WithUserID(ctx context.Context, userID any) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// RequestID 从 context 取请求 ID，兼容直接传入 *gin.Context 时的 "request_id" 键
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if v, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return v
+	}
+	if v, ok := ctx.Value("request_id").(string); ok {
+		return v
+	}
+	return ""
+}
+
+// UserID 从 context 取用户 ID，兼容直接传入 *gin.Context 时的 "uid" 键
+func UserID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	v := ctx.Value(userIDKey{})
+	if v == nil {
+		v = ctx.Value("uid")
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// ContextFields 返回 context 中的请求 ID 和用户 ID 日志字段
+func ContextFields(ctx context.Context) []zap.Field {
+	var fields []zap.Field
+	if id := RequestID(ctx); id != "" {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	if id := UserID(ctx); id != "" {
+		fields = append(fields, zap.String("user_id", id))
+	}
+	return fields
+}
@@ -12,3 +12,24 @@ type BaseModel struct {
 	UpdatedAt time.Time      `json:"-"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// VersionedModel 带乐观锁版本号的基础模型，配合 repository.Repository 的 Update 使用
+type VersionedModel struct {
+	BaseModel
+	Version int64 `gorm:"not null;default:0" json:"version"`
+}
+
+// AuditedModel 带操作人字段的基础模型，数据源开启 Audit 后由 audit 插件自动填充
+type AuditedModel struct {
+	BaseModel
+	CreatedBy string `gorm:"size:64" json:"created_by"`
+	UpdatedBy string `gorm:"size:64" json:"updated_by"`
+}
+
+// TenantModel 按租户隔离的模型嵌入该结构体，数据源开启 Tenant 后查询和写入自动按租户过滤
+type TenantModel struct {
+	TenantID string `gorm:"size:64;index" json:"tenant_id"`
+}
+
+// TenantScoped 实现 tenant.TenantScoped
+func (TenantModel) TenantScoped() {}
@@ -0,0 +1,137 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/streadway/amqp"
+	"github.com/trancecho/open-sdk/config"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Message 待发布的消息
+type Message struct {
+	Exchange    string
+	RoutingKey  string
+	ContentType string
+	MessageID   string // 供消费者去重
+	Headers     map[string]any
+	Body        []byte
+}
+
+// ErrUnroutable 消息没有路由到任何队列，被 broker 退回
+var ErrUnroutable = errors.New("message unroutable")
+
+// Publisher 消息发布者，Publish 返回 nil 表示 broker 已确认收到
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// URLFromConfig 根据全局配置的 MQ 段拼出 amqp 地址
+func URLFromConfig() string {
+	conf := config.GetConfig().MQ
+	u := url.URL{
+		Scheme: "amqp",
+		User:   url.UserPassword(conf.Username, conf.Password),
+		Host:   fmt.Sprintf("%s:%s", conf.Address, conf.Port),
+		Path:   "/" + conf.VirtualHost,
+	}
+	return u.String()
+}
+
+// RabbitPublisher 开启 publisher confirm 的 RabbitMQ 发布者，并发安全
+type RabbitPublisher struct {
+	url      string
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+}
+
+// NewRabbitPublisher 连接 RabbitMQ，连接断开后下一次 Publish 会自动重连
+func NewRabbitPublisher(amqpURL string) (*RabbitPublisher, error) {
+	p := &RabbitPublisher{url: amqpURL}
+	if err := p.connect(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *RabbitPublisher) connect() error {
+	conn, err := amqp.Dial(p.url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+	if err = ch.Confirm(false); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	p.conn, p.ch = conn, ch
+	p.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	// mandatory 消息无法路由时 broker 先发 basic.return 再确认，需在确认后检查是否被退回
+	p.returns = ch.NotifyReturn(make(chan amqp.Return, 1))
+	return nil
+}
+
+func (p *RabbitPublisher) Publish(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil || p.conn.IsClosed() {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	err := p.ch.Publish(msg.Exchange, msg.RoutingKey, true, false, amqp.Publishing{
+		ContentType:  contentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    msg.MessageID,
+		Headers:      amqp.Table(msg.Headers),
+		Timestamp:    time.Now(),
+		Body:         msg.Body,
+	})
+	if err != nil {
+		_ = p.conn.Close()
+		return fmt.Errorf("failed to publish a message: %w", err)
+	}
+	select {
+	case confirm, ok := <-p.confirms:
+		if !ok {
+			return fmt.Errorf("channel closed before publish confirm")
+		}
+		if !confirm.Ack {
+			return fmt.Errorf("message %s nacked by broker", msg.MessageID)
+		}
+		select {
+		case ret := <-p.returns:
+			return fmt.Errorf("message %s returned by broker: %s: %w", ret.MessageId, ret.ReplyText, ErrUnroutable)
+		default:
+		}
+		return nil
+	case <-ctx.Done():
+		// 确认丢失后通道状态不可信，重建连接
+		_ = p.conn.Close()
+		return ctx.Err()
+	}
+}
+
+func (p *RabbitPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil || p.conn.IsClosed() {
+		return nil
+	}
+	return p.conn.Close()
+}
@@ -0,0 +1,341 @@
+package page
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trancecho/open-sdk/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidCursor 游标被篡改、签名密钥不匹配或与当前排序不一致
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrNoCursorSecret 无法生成随机签名密钥
+	ErrNoCursorSecret = errors.New("cursor secret not configured")
+)
+
+var (
+	cursorSecret []byte
+	randomSecret []byte
+	secretMux    sync.RWMutex
+)
+
+// SetCursorSecret 设置游标签名密钥，设置后优先于配置中的 Pagination.CursorSecret。
+// 两者都为空时使用进程内随机密钥，多实例部署或重启后游标会失效，因此各实例需配置相同的密钥
+func SetCursorSecret(secret []byte) {
+	secretMux.Lock()
+	defer secretMux.Unlock()
+	cursorSecret = append([]byte(nil), secret...)
+}
+
+// SortColumn 游标分页的排序列，最后一列应唯一（如主键），否则可能漏掉或重复记录
+// 排序列的值不能为 NULL，可为空的列应配合 COALESCE 等方式在查询外保证非空，或不作为排序列
+type SortColumn struct {
+	Column string
+	Desc   bool
+}
+
+// ParseSort 解析 "name,-id" 形式的排序，前缀 - 表示倒序
+func ParseSort(sort ...string) []SortColumn {
+	columns := make([]SortColumn, 0, len(sort))
+	for _, s := range sort {
+		columns = append(columns, SortColumn{Column: strings.TrimPrefix(s, "-"), Desc: strings.HasPrefix(s, "-")})
+	}
+	return columns
+}
+
+func sortSignature(columns []SortColumn) string {
+	parts := make([]string, len(columns))
+	for i, c := range columns {
+		parts[i] = c.Column
+		if c.Desc {
+			parts[i] = "-" + c.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// cursorValue 带类型的游标值，保证解码后与数据库列的比较类型一致
+type cursorValue struct {
+	T string          `json:"t"`
+	V json.RawMessage `json:"v"`
+}
+
+type cursorPayload struct {
+	Sort   string        `json:"s"`
+	Values []cursorValue `json:"v"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// EncodeCursor 把最后一条记录的排序列值编码为签名后的不透明游标。
+// 支持基础类型及以其为底层类型的自定义类型、time.Time，以及 sql.NullString、gorm.DeletedAt 等 driver.Valuer。
+// Valuer 必须持有有效值，NULL 不能作为游标值，会返回错误
+func EncodeCursor(columns []SortColumn, values []any) (string, error) {
+	payload := cursorPayload{Sort: sortSignature(columns)}
+	for _, v := range values {
+		cv, err := encodeValue(v)
+		if err != nil {
+			return "", err
+		}
+		payload.Values = append(payload.Values, cv)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	mac, err := sign(data)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+func encodeValue(v any) (cursorValue, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return cursorValue{}, errors.New("cursor value cannot be nil")
+		}
+		dv, err := valuer.Value()
+		if err != nil {
+			return cursorValue{}, err
+		}
+		v = dv
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return cursorValue{}, errors.New("cursor value cannot be nil")
+		}
+		return encodeValue(rv.Elem().Interface())
+	}
+	if !rv.IsValid() {
+		return cursorValue{}, errors.New("cursor value cannot be NULL")
+	}
+	var (
+		typ string
+		raw any
+	)
+	switch {
+	case rv.Type().ConvertibleTo(timeType) && rv.Kind() == reflect.Struct:
+		typ, raw = "t", rv.Convert(timeType).Interface().(time.Time).Format(time.RFC3339Nano)
+	case rv.Kind() == reflect.String:
+		typ, raw = "s", rv.String()
+	case rv.Kind() == reflect.Bool:
+		typ, raw = "b", rv.Bool()
+	case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+		typ, raw = "f", rv.Float()
+	case rv.Kind() >= reflect.Int && rv.Kind() <= reflect.Int64:
+		typ, raw = "i", rv.Int()
+	case rv.Kind() >= reflect.Uint && rv.Kind() <= reflect.Uint64:
+		// 超过 int64 范围的值单独标记，解码为 uint64
+		typ, raw = "u", strconv.FormatUint(rv.Uint(), 10)
+	default:
+		return cursorValue{}, fmt.Errorf("unsupported cursor value type %T", v)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return cursorValue{}, err
+	}
+	return cursorValue{T: typ, V: data}, nil
+}
+
+// DecodeCursor 校验签名并还原排序列值，排序与编码时不一致时返回 ErrInvalidCursor
+func DecodeCursor(columns []SortColumn, cursor string) ([]any, error) {
+	body, sig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	data, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	expected, err := sign(data)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, expected) {
+		return nil, ErrInvalidCursor
+	}
+	var payload cursorPayload
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if payload.Sort != sortSignature(columns) || len(payload.Values) != len(columns) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, len(payload.Values))
+	for i, cv := range payload.Values {
+		if values[i], err = decodeValue(cv); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+func decodeValue(cv cursorValue) (any, error) {
+	switch cv.T {
+	case "t":
+		var s string
+		if err := json.Unmarshal(cv.V, &s); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case "s":
+		var s string
+		err := json.Unmarshal(cv.V, &s)
+		return s, err
+	case "b":
+		var b bool
+		err := json.Unmarshal(cv.V, &b)
+		return b, err
+	case "f":
+		var f float64
+		err := json.Unmarshal(cv.V, &f)
+		return f, err
+	case "i":
+		dec := json.NewDecoder(bytes.NewReader(cv.V))
+		dec.UseNumber()
+		var n json.Number
+		if err := dec.Decode(&n); err != nil {
+			return nil, err
+		}
+		return n.Int64()
+	case "u":
+		var s string
+		if err := json.Unmarshal(cv.V, &s); err != nil {
+			return nil, err
+		}
+		return strconv.ParseUint(s, 10, 64)
+	}
+	return nil, fmt.Errorf("unknown cursor value type %q", cv.T)
+}
+
+func sign(data []byte) ([]byte, error) {
+	secret, err := currentSecret()
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, secret)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// currentSecret 依次使用 SetCursorSecret 设置的密钥、配置中的 Pagination.CursorSecret、进程内随机密钥
+func currentSecret() ([]byte, error) {
+	secretMux.RLock()
+	secret := cursorSecret
+	secretMux.RUnlock()
+	if len(secret) > 0 {
+		return secret, nil
+	}
+	if conf := config.GetConfig(); conf != nil && conf.Pagination.CursorSecret != "" {
+		return []byte(conf.Pagination.CursorSecret), nil
+	}
+	secretMux.Lock()
+	defer secretMux.Unlock()
+	if len(randomSecret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNoCursorSecret, err)
+		}
+		randomSecret = secret
+		log.Println("page: Pagination.CursorSecret not configured, using a random secret; cursors will not work across instances or restarts")
+	}
+	return randomSecret, nil
+}
+
+// Keyset 游标分页的 scope：按排序列排序，从游标之后开始，多取一条用于判断是否有下一页
+// cursor 为空表示第一页。列名需由调用方保证来自模型字段
+func Keyset(columns []SortColumn, cursor string, limit int) (func(db *gorm.DB) *gorm.DB, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("keyset pagination requires sort columns")
+	}
+	_, limit = Normalize(1, limit)
+	var after []any
+	if cursor != "" {
+		var err error
+		if after, err = DecodeCursor(columns, cursor); err != nil {
+			return nil, err
+		}
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		if after != nil {
+			db = db.Where(keysetCondition(columns, after))
+		}
+		for _, c := range columns {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: c.Column}, Desc: c.Desc})
+		}
+		return db.Limit(limit + 1)
+	}, nil
+}
+
+// keysetCondition 生成 (a > ?) OR (a = ? AND b > ?) ... 形式的条件，倒序列使用 <
+func keysetCondition(columns []SortColumn, values []any) clause.Expression {
+	ors := make([]clause.Expression, 0, len(columns))
+	for i, c := range columns {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: column(columns[j].Column), Value: values[j]})
+		}
+		if c.Desc {
+			ands = append(ands, clause.Lt{Column: column(c.Column), Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: column(c.Column), Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	return clause.Or(ors...)
+}
+
+func column(name string) clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: name}
+}
+
+// CursorResult 根据 Keyset 查询出的记录（最多 limit+1 条）构造游标分页结果
+// total 由调用方单独统计，不需要时传 0
+func CursorResult[T any](db *gorm.DB, items []T, columns []SortColumn, limit int, total int64) (PageResult[T], error) {
+	_, limit = Normalize(1, limit)
+	result := PageResult[T]{Items: items, Total: total, Limit: limit}
+	if result.Items == nil {
+		result.Items = []T{}
+	}
+	if len(items) <= limit {
+		return result, nil
+	}
+	result.Items, result.HasNext = items[:limit], true
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return result, err
+	}
+	s := stmt.Schema
+	last := result.Items[limit-1]
+	values := make([]any, len(columns))
+	for i, c := range columns {
+		field := s.LookUpField(c.Column)
+		if field == nil {
+			return result, fmt.Errorf("sort column %s not found in %s", c.Column, s.Name)
+		}
+		values[i], _ = field.ValueOf(db.Statement.Context, reflect.ValueOf(&last))
+	}
+	var err error
+	result.NextCursor, err = EncodeCursor(columns, values)
+	return result, err
+}
@@ -4,17 +4,22 @@ import "gorm.io/gorm"
 
 func Paginate(page int, limit int) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		if page == 0 {
-			page = 1
-		}
-		switch {
-		case limit > 100:
-			limit = 100
-		case limit <= 0:
-			limit = 10
-		}
-
+		page, limit := Normalize(page, limit)
 		offset := (page - 1) * limit
 		return db.Offset(offset).Limit(limit)
 	}
 }
+
+// Normalize 页码从 1 开始，每页默认 10 条，最多 100 条
+func Normalize(page int, limit int) (int, int) {
+	if page <= 0 {
+		page = 1
+	}
+	switch {
+	case limit > 100:
+		limit = 100
+	case limit <= 0:
+		limit = 10
+	}
+	return page, limit
+}
@@ -0,0 +1,27 @@
+package page
+
+// PageResult 分页结果，可直接作为 libx.Ok 的 data 返回
+// 游标分页时 NextCursor 为下一页的游标，偏移分页时 Page/Limit 为当前页参数
+type PageResult[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	HasNext    bool   `json:"has_next"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// NewPageResult 构造偏移分页结果，page/limit 按 Paginate 的规则归一化
+func NewPageResult[T any](items []T, total int64, page, limit int) PageResult[T] {
+	page, limit = Normalize(page, limit)
+	if items == nil {
+		items = []T{}
+	}
+	return PageResult[T]{
+		Items:   items,
+		Total:   total,
+		HasNext: int64(page*limit) < total,
+		Page:    page,
+		Limit:   limit,
+	}
+}